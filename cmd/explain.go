@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/danztran/telescope/config"
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/handler"
+	"github.com/danztran/telescope/pkg/httpclient"
+	"github.com/danztran/telescope/pkg/kube"
+	"github.com/danztran/telescope/pkg/scope"
+	"github.com/spf13/cobra"
+)
+
+var explainFlags struct {
+	src    string
+	dest   string
+	server string
+	format string
+}
+
+// explainCmd explain why connections are exposed or dropped by the collector
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Explain why connections from src to dest are exposed or dropped",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		var decisions []collector.Decision
+		var err error
+		if explainFlags.server != "" {
+			decisions, err = explainFromServer(ctx)
+		} else {
+			decisions, err = explainFromCycle(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch explainFlags.format {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(decisions)
+		case "table", "":
+		default:
+			return fmt.Errorf("invalid format: %s", explainFlags.format)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SRC\tDEST\tPORT\tPORTS\tDECISION\tRULE")
+		for _, d := range decisions {
+			src, dest := d.Source, d.Destination
+			if d.SourceObject != "" {
+				src = d.SourceObject
+			}
+			if d.DestinationObject != "" {
+				dest = d.DestinationObject
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				src, dest, d.DestinationPort, strings.Join(d.Ports, ","), d.Reason, d.Rule)
+		}
+		return w.Flush()
+	},
+}
+
+// explainFromServer get decisions of the last cycle of a running telescope
+func explainFromServer(ctx context.Context) ([]collector.Decision, error) {
+	client, err := httpclient.NewClient(httpclient.Config{
+		Address: explainFlags.server,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := client.URL("/v1/debug/edges", nil)
+	u.RawQuery = url.Values{
+		"src":  {explainFlags.src},
+		"dest": {explainFlags.dest},
+	}.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error create new request / %w", err)
+	}
+
+	resp := new(handler.ExplainEdgesResponse)
+	if _, _, err := client.Do(ctx, req, resp); err != nil {
+		return nil, fmt.Errorf("error get decisions / %w", err)
+	}
+
+	return resp.Decisions, nil
+}
+
+// explainFromCycle run a collection cycle and get its decisions
+func explainFromCycle(ctx context.Context) ([]collector.Decision, error) {
+	ScopeClient, err := scope.New(scope.Deps{
+		Config: config.Values.Scope,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	Kube, err := kube.New(kube.Deps{
+		Config: kube.DefaultConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	Collector, err := collector.New(collector.Deps{
+		Scope:  ScopeClient,
+		Kube:   Kube,
+		Config: config.Values.Collector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Collector.Collect(ctx); err != nil {
+		return nil, err
+	}
+
+	return Collector.Explain(explainFlags.src, explainFlags.dest), nil
+}
+
+func init() {
+	flags := explainCmd.Flags()
+	flags.StringVar(&explainFlags.src, "src", "", "source node label, workload name or namespace/name")
+	flags.StringVar(&explainFlags.dest, "dest", "", "destination node label, workload name or namespace/name")
+	flags.StringVarP(&explainFlags.server, "server", "s", "", "address of a running telescope, a local collection cycle if not set")
+	flags.StringVarP(&explainFlags.format, "format", "f", "table", "output format: table, json")
+	rootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/danztran/telescope/config"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshexport"
+	"github.com/spf13/cobra"
+)
+
+var exportFlags struct {
+	format string
+	output string
+}
+
+// exportCmd render the mesh graph to a diagram/graph tool format
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the mesh graph as dot, mermaid, graphml or cytoscape",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := meshexport.ParseFormat(exportFlags.format)
+		if err != nil {
+			return err
+		}
+
+		MetricsClient, closeMetricsClient, err := newMetricsClient(nil, nil)
+		if err != nil {
+			return err
+		}
+		defer closeMetricsClient()
+
+		Mapnode, err := mapnode.New(mapnode.Deps{
+			MetricsClient: MetricsClient,
+			Config:        config.Values.Mapnode,
+		})
+		if err != nil {
+			return err
+		}
+
+		out := os.Stdout
+		if exportFlags.output != "" && exportFlags.output != "-" {
+			out, err = os.Create(exportFlags.output)
+			if err != nil {
+				return err
+			}
+			defer out.Close()
+		}
+
+		return meshexport.Render(out, format, Mapnode.GetAllNodes())
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFlags.format, "format", "f", string(meshexport.FormatDOT), "output format: dot, mermaid, graphml, cytoscape")
+	exportCmd.Flags().StringVarP(&exportFlags.output, "output", "o", "-", "output file, - for stdout")
+	rootCmd.AddCommand(exportCmd)
+}
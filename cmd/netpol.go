@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/danztran/telescope/config"
+	"github.com/danztran/telescope/pkg/kube"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/netpol"
+	"github.com/spf13/cobra"
+)
+
+var netpolFlags struct {
+	namespace string
+	window    time.Duration
+	dryRun    bool
+	output    string
+}
+
+// netpolCmd group network policy commands
+var netpolCmd = &cobra.Command{
+	Use:   "netpol",
+	Short: "Kubernetes NetworkPolicies from observed connections",
+}
+
+// netpolGenerateCmd generate network policies from the observed mesh graph
+var netpolGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate least-privilege NetworkPolicies per workload",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		Kube, err := kube.New(kube.Deps{
+			Config: kube.DefaultConfig,
+		})
+		if err != nil {
+			return err
+		}
+
+		MetricsClient, closeMetricsClient, err := newMetricsClient(nil, nil)
+		if err != nil {
+			return err
+		}
+		defer closeMetricsClient()
+
+		opt := netpol.Options{
+			Namespace: netpolFlags.namespace,
+			DryRun:    netpolFlags.dryRun,
+		}
+		if netpolFlags.window > 0 {
+			opt.Window = &netpolFlags.window
+		}
+
+		var Mapnode mapnode.Mapnode
+		if opt.Window == nil && config.Values.Netpol.Window == nil {
+			Mapnode, err = mapnode.New(mapnode.Deps{
+				MetricsClient: MetricsClient,
+				Config:        config.Values.Mapnode,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		Netpol, err := netpol.New(netpol.Deps{
+			Kube:          Kube,
+			Mapnode:       Mapnode,
+			MetricsClient: MetricsClient,
+			Config:        config.Values.Netpol,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := Netpol.Generate(context.Background(), opt)
+		if err != nil {
+			return err
+		}
+		for _, id := range result.Unresolved {
+			log.Warnf("not found workload of node: %s", id)
+		}
+		for _, id := range result.Incomplete {
+			log.Warnf("skipped policy of node with unselectable peers: %s", id)
+		}
+
+		out := os.Stdout
+		if netpolFlags.output != "" && netpolFlags.output != "-" {
+			out, err = os.Create(netpolFlags.output)
+			if err != nil {
+				return err
+			}
+			defer out.Close()
+		}
+
+		if !opt.DryRun {
+			return netpol.Render(out, result.Policies)
+		}
+
+		for _, diff := range result.Diffs {
+			if _, err := fmt.Fprintf(out, "# %s %s/%s\n", diff.Action, diff.Namespace, diff.Name); err != nil {
+				return err
+			}
+			if diff.Text != "" {
+				if _, err := fmt.Fprintln(out, diff.Text); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	flags := netpolGenerateCmd.Flags()
+	flags.StringVarP(&netpolFlags.namespace, "namespace", "n", "", "generate policies of a namespace only")
+	flags.DurationVarP(&netpolFlags.window, "window", "w", 0, "window of observed connections, netpol.window config if not set")
+	flags.BoolVar(&netpolFlags.dryRun, "dry-run", false, "print the diff with policies in the cluster instead of manifests")
+	flags.StringVarP(&netpolFlags.output, "output", "o", "-", "output file, - for stdout")
+	netpolCmd.AddCommand(netpolGenerateCmd)
+	rootCmd.AddCommand(netpolCmd)
+}
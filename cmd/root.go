@@ -9,12 +9,22 @@ import (
 
 	"github.com/danztran/telescope/config"
 	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/election"
 	"github.com/danztran/telescope/pkg/handler"
 	"github.com/danztran/telescope/pkg/kube"
 	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshql"
+	"github.com/danztran/telescope/pkg/meshrpc"
+	"github.com/danztran/telescope/pkg/netaudit"
+	"github.com/danztran/telescope/pkg/netpol"
+	"github.com/danztran/telescope/pkg/notifier"
+	"github.com/danztran/telescope/pkg/policy"
 	"github.com/danztran/telescope/pkg/promscope"
+	"github.com/danztran/telescope/pkg/remotewrite"
 	"github.com/danztran/telescope/pkg/scope"
 	"github.com/danztran/telescope/pkg/server"
+	"github.com/danztran/telescope/pkg/shard"
+	"github.com/danztran/telescope/pkg/storage"
 	"github.com/danztran/telescope/pkg/utils"
 	"github.com/spf13/cobra"
 )
@@ -28,60 +38,230 @@ var rootCmd = &cobra.Command{
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ScopeClient := scope.MustNew(scope.Deps{
-			Config: config.Values.Scope,
+			Config:           config.Values.Scope,
+			MetricsNamespace: config.Values.Collector.Metrics.Namespace,
+			MetricsSubsystem: config.Values.Collector.Metrics.Subsystem,
 		})
 
 		Kube := kube.MustNew(kube.Deps{
 			Config: kube.DefaultConfig,
 		})
 
+		if config.Values.Election.Enabled && config.Values.Shard.Enabled {
+			return fmt.Errorf("election and shard cannot be both enabled")
+		}
+		// followers do not collect, so they would serve an empty mesh
+		if config.Values.Election.Enabled && config.Values.Mapnode.Source == mapnode.SourceDirect {
+			return fmt.Errorf("election cannot be enabled with the %s mapnode source", mapnode.SourceDirect)
+		}
+		// a shard only holds the edges of its nodes, readers of all the edges
+		// of the collector would miss the shares of other replicas
+		if config.Values.Shard.Enabled {
+			switch {
+			case config.Values.Mapnode.Source == mapnode.SourceDirect:
+				return fmt.Errorf("shard cannot be enabled with the %s mapnode source", mapnode.SourceDirect)
+			case config.Values.Policy.File != "":
+				return fmt.Errorf("shard cannot be enabled with policy")
+			case config.Values.Storage.Enabled:
+				return fmt.Errorf("shard cannot be enabled with storage")
+			}
+		}
+
+		electionDeps := election.Deps{
+			Config: config.Values.Election,
+		}
+		shardDeps := shard.Deps{
+			Config: config.Values.Shard,
+		}
+		if config.Values.Election.Enabled || config.Values.Shard.Discovery == shard.DiscoveryLease {
+			client, err := kube.NewClient(kube.DefaultConfig.Kubeconfig)
+			if err != nil {
+				return err
+			}
+			electionDeps.Client = client
+			shardDeps.Client = client
+		}
+		Elector := election.MustNew(electionDeps)
+
+		var Sharder shard.Sharder
+		if config.Values.Shard.Enabled {
+			Sharder = shard.MustNew(shardDeps)
+		}
+
 		Collector := collector.MustNew(collector.Deps{
-			Scope:  ScopeClient,
-			Kube:   Kube,
-			Config: config.Values.Collector,
+			Scope:   ScopeClient,
+			Kube:    Kube,
+			Sharder: Sharder,
+			Config:  config.Values.Collector,
 		})
 
-		Promscope := promscope.MustNew(promscope.Deps{
-			Config: config.Values.Promscope,
-		})
+		var Storage storage.Storage
+		if config.Values.Storage.Enabled {
+			Storage = storage.MustNew(storage.Deps{
+				Collector: Collector,
+				Config:    config.Values.Storage,
+			})
+			defer Storage.Close()
+		}
+
+		MetricsClient, closeMetricsClient, err := newMetricsClient(Storage, Collector)
+		if err != nil {
+			return err
+		}
+		defer closeMetricsClient()
 
 		Mapnode := mapnode.MustNew(mapnode.Deps{
-			MetricsClient: Promscope,
+			MetricsClient: MetricsClient,
 			Config:        config.Values.Mapnode,
 		})
 
+		Policy := policy.MustNew(policy.Deps{
+			Collector: Collector,
+			Mapnode:   Mapnode,
+			Metrics:   config.Values.Collector.Metrics,
+			Config:    config.Values.Policy,
+		})
+
+		Netpol := netpol.MustNew(netpol.Deps{
+			Kube:          Kube,
+			Mapnode:       Mapnode,
+			MetricsClient: MetricsClient,
+			Config:        config.Values.Netpol,
+		})
+
+		Netaudit := netaudit.MustNew(netaudit.Deps{
+			Kube:      Kube,
+			Collector: Collector,
+			Metrics:   config.Values.Collector.Metrics,
+			Config:    config.Values.Netaudit,
+		})
+
 		Handler := handler.MustNew(handler.Deps{
+			Collector: Collector,
+			Mapnode:   Mapnode,
+			Policy:    Policy,
+			Netpol:    Netpol,
+			Netaudit:  Netaudit,
+			Storage:   Storage,
+		})
+
+		MeshQL := meshql.MustNew(meshql.Deps{
 			Mapnode: Mapnode,
+			Config:  config.Values.GraphQL,
 		})
 
 		Server := server.MustNew(server.Deps{
 			Handler: Handler,
+			MeshQL:  MeshQL,
+			Elector: Elector,
 			Config:  config.Values.Server,
 		})
 
+		RPC := meshrpc.MustNew(meshrpc.Deps{
+			Mapnode: Mapnode,
+			Config:  config.Values.GRPC,
+		})
+
+		Notifier := notifier.MustNew(notifier.Deps{
+			Mapnode: Mapnode,
+			Config:  config.Values.Notifier,
+		})
+
+		RemoteWrite := remotewrite.MustNew(remotewrite.Deps{
+			Metrics: config.Values.Collector.Metrics,
+		})
+
 		wg := sync.WaitGroup{}
 		ctx, cancel := context.WithCancel(context.Background())
 
-		var err error
-		go utils.RunJobsWithContext(
-			ctx,
-			&wg,
-			Collector.RunCollectInterval,
-			Collector.RunResetInterval,
+		var rpcErr error
+		jobs := []func(context.Context){
+			// only the leader collects, notifies & pushes,
+			// all replicas serve the mesh
+			func(ctx context.Context) {
+				Elector.Run(ctx,
+					Collector.RunCollectInterval,
+					Collector.RunResetInterval,
+					Collector.RunOTLPInterval,
+					Notifier.Run,
+					RemoteWrite.Run,
+					func(ctx context.Context) {
+						// stop exposing edges once the leadership is lost
+						<-ctx.Done()
+						if err := Collector.Reset(); err != nil {
+							log.Error(err)
+						}
+					},
+				)
+			},
 			Mapnode.RunUpdateInterval,
+			Policy.Run,
+			Netaudit.Run,
+			func(ctx context.Context) {
+				err := config.Watch(ctx, func(values config.Config) error {
+					// validate all configs before applying any, so a config is
+					// applied whole or not at all
+					reloadable, ok := MetricsClient.(promscope.Reloadable)
+					if ok {
+						if err := values.Promscope.Validate(); err != nil {
+							return fmt.Errorf("promscope: %w", err)
+						}
+					}
+					if err := values.Collector.Validate(); err != nil {
+						return fmt.Errorf("collector: %w", err)
+					}
+					if err := values.Mapnode.Validate(); err != nil {
+						return fmt.Errorf("mapnode: %w", err)
+					}
+
+					// promscope first, the only one failing on a valid config
+					// while creating its client
+					if ok {
+						if err := reloadable.Reload(values.Promscope); err != nil {
+							return fmt.Errorf("promscope: %w", err)
+						}
+					}
+					if err := Collector.Reload(values.Collector); err != nil {
+						return fmt.Errorf("collector: %w", err)
+					}
+					if err := Mapnode.Reload(values.Mapnode); err != nil {
+						return fmt.Errorf("mapnode: %w", err)
+					}
+					return nil
+				})
+				if err != nil {
+					log.Error(err)
+				}
+			},
 			func(ctx context.Context) {
 				err = Server.Run(ctx)
 				if err != nil {
 					err = fmt.Errorf("server error / %w", err)
 				}
 			},
-		)
+			func(ctx context.Context) {
+				rpcErr = RPC.Run(ctx)
+				if rpcErr != nil {
+					rpcErr = fmt.Errorf("grpc server error / %w", rpcErr)
+				}
+			},
+		}
+		if Storage != nil {
+			jobs = append(jobs, Storage.Run)
+		}
+		if Sharder != nil {
+			jobs = append(jobs, Sharder.Run)
+		}
+		go utils.RunJobsWithContext(ctx, &wg, jobs...)
 
 		utils.WaitToStop()
 		log.Infof("terminating...")
 		cancel()
 		wg.Wait()
 
+		if err == nil {
+			err = rpcErr
+		}
 		return err
 	},
 }
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/danztran/telescope/config"
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/promscope"
+	"github.com/danztran/telescope/pkg/storage"
+)
+
+// newMetricsClient create the MetricsClient feeding mapnode, by mapnode.source.
+// Storage is opened if it is the source and not given, closed by the returned func.
+// The direct source need the in-process collector.
+func newMetricsClient(store storage.Storage, c collector.Collector) (mapnode.MetricsClient, func(), error) {
+	noop := func() {}
+	source := config.Values.Mapnode.Source
+
+	switch source {
+	case mapnode.SourcePrometheus, mapnode.SourceThanos, mapnode.SourceCortex, mapnode.SourceMimir, mapnode.SourceVictoriaMetrics, "":
+		client, err := promscope.NewReloadable(promscope.Deps{
+			Config: config.Values.Promscope,
+			Flavor: source,
+			Metric: config.Values.Collector.Metrics.Name(promscope.ConnectionMetric),
+
+			MetricsNamespace: config.Values.Collector.Metrics.Namespace,
+			MetricsSubsystem: config.Values.Collector.Metrics.Subsystem,
+		})
+		return client, noop, err
+
+	case mapnode.SourceDirect:
+		if c == nil {
+			return nil, noop, fmt.Errorf("direct source is only available to the server")
+		}
+		return collector.NewDirect(c), noop, nil
+
+	case mapnode.SourceStorage:
+		if store != nil {
+			return store, noop, nil
+		}
+		opened, err := storage.New(storage.Deps{
+			Config: config.Values.Storage,
+		})
+		if err != nil {
+			return nil, noop, err
+		}
+		return opened, func() { opened.Close() }, nil
+	}
+
+	return nil, noop, fmt.Errorf("unknown mapnode source: %s", source)
+}
@@ -1,15 +1,25 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strings"
 
 	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/election"
 	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshql"
+	"github.com/danztran/telescope/pkg/meshrpc"
+	"github.com/danztran/telescope/pkg/netaudit"
+	"github.com/danztran/telescope/pkg/netpol"
+	"github.com/danztran/telescope/pkg/notifier"
+	"github.com/danztran/telescope/pkg/policy"
 	"github.com/danztran/telescope/pkg/promscope"
 	"github.com/danztran/telescope/pkg/scope"
 	"github.com/danztran/telescope/pkg/server"
+	"github.com/danztran/telescope/pkg/shard"
+	"github.com/danztran/telescope/pkg/storage"
 	"github.com/spf13/viper"
 )
 
@@ -21,10 +31,22 @@ type Config struct {
 	Collector collector.Config `mapstructure:"collector"`
 	Promscope promscope.Config `mapstructure:"promscope"`
 	Mapnode   mapnode.Config   `mapstructure:"mapnode"`
+	GraphQL   meshql.Config    `mapstructure:"graphql"`
+	GRPC      meshrpc.Config   `mapstructure:"grpc"`
+	Notifier  notifier.Config  `mapstructure:"notifier"`
+	Policy    policy.Config    `mapstructure:"policy"`
+	Netpol    netpol.Config    `mapstructure:"netpol"`
+	Netaudit  netaudit.Config  `mapstructure:"netaudit"`
+	Storage   storage.Config   `mapstructure:"storage"`
+	Election  election.Config  `mapstructure:"election"`
+	Shard     shard.Config     `mapstructure:"shard"`
 }
 
+// file is the path of the loaded config file, watched for reloads
+var file string
+
 func init() {
-	config := viper.New()
+	config := newViper()
 	config.SetConfigName("telescope-config") // config file name
 	if configPath, ok := os.LookupEnv("TELESCOPE_CONFIG"); ok {
 		config.AddConfigPath(configPath)
@@ -33,16 +55,58 @@ func init() {
 	config.AddConfigPath("./config/")
 	config.AddConfigPath("../config/")
 	config.AddConfigPath("../../config/")
+
+	values, err := read(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	Values = values
+	file = config.ConfigFileUsed()
+}
+
+func newViper() *viper.Viper {
+	config := viper.New()
 	config.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
 	config.AutomaticEnv()
+	return config
+}
+
+// read, parse & validate the config
+func read(config *viper.Viper) (Config, error) {
+	values := Config{}
 
 	err := config.ReadInConfig()
 	if err != nil {
-		log.Fatalf("error read config / %s", err)
+		return values, fmt.Errorf("error read config / %w", err)
 	}
 
-	err = config.Unmarshal(&Values)
+	err = config.Unmarshal(&values)
 	if err != nil {
-		log.Fatalf("error parse config / %s", err)
+		return values, fmt.Errorf("error parse config / %w", err)
+	}
+
+	err = values.Validate()
+	if err != nil {
+		return values, fmt.Errorf("error validate config / %w", err)
+	}
+
+	return values, nil
+}
+
+// Validate check settings reloadable at runtime
+func (c Config) Validate() error {
+	if err := c.Collector.Validate(); err != nil {
+		return fmt.Errorf("collector: %w", err)
+	}
+	if err := c.Mapnode.Validate(); err != nil {
+		return fmt.Errorf("mapnode: %w", err)
+	}
+	switch c.Mapnode.Source {
+	case mapnode.SourceDirect, mapnode.SourceStorage:
+	default:
+		if err := c.Promscope.Validate(); err != nil {
+			return fmt.Errorf("promscope: %w", err)
+		}
 	}
+	return nil
 }
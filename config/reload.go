@@ -0,0 +1,163 @@
+package config
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reloadDelay gather the events of a config file update into one reload
+const reloadDelay = time.Second
+
+// results of config reloads
+const (
+	ReloadSuccess string = "success"
+	ReloadFailure string = "failure"
+)
+
+var (
+	reloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "config_last_reload_successful",
+		Help: "Whether the last config reload succeeded.",
+	})
+	reloadTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "config_last_reload_success_timestamp_seconds",
+		Help: "Timestamp of the last successful config reload.",
+	})
+	reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_reloads_total",
+		Help: "Config reloads, by result.",
+	}, []string{"result"})
+	configHash = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "config_hash",
+		Help: "Hash of the applied config file.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(reloadSuccessful, reloadTimestamp, reloads, configHash)
+}
+
+// Watch reload the config file on changes until the context is done.
+// Reloaded values are validated, then passed to apply, and the previous
+// values are kept if either fails.
+func Watch(ctx context.Context, apply func(values Config) error) error {
+	log := utils.MustGetLogger("config")
+
+	hash, err := hashFile(file)
+	if err != nil {
+		return err
+	}
+	setApplied(hash)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error create config watcher / %w", err)
+	}
+	defer watcher.Close()
+
+	// watch the directory to follow atomic saves & ConfigMap symlink swaps
+	path := filepath.Clean(file)
+	realPath, _ := filepath.EvalSymlinks(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("error watch config %s / %w", path, err)
+	}
+	log.Infof("watching config %s", path)
+
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			current, _ := filepath.EvalSymlinks(path)
+			changed := filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+			if changed || (current != "" && current != realPath) {
+				realPath = current
+				timer.Reset(reloadDelay)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Errorf("error watch config / %s", err)
+
+		case <-timer.C:
+			next, err := reload(hash, apply)
+			if err != nil {
+				reloads.WithLabelValues(ReloadFailure).Inc()
+				reloadSuccessful.Set(0)
+				log.Errorf("error reload config, keeping the previous one / %s", err)
+				continue
+			}
+			if next == hash {
+				// the file is back to the applied config after a failed reload
+				reloadSuccessful.Set(1)
+				continue
+			}
+			hash = next
+			reloads.WithLabelValues(ReloadSuccess).Inc()
+			setApplied(hash)
+			log.Infof("reloaded config %s", path)
+		}
+	}
+}
+
+// reload read & apply the config file if its hash changed,
+// returning the hash of the applied file
+func reload(hash float64, apply func(values Config) error) (float64, error) {
+	next, err := hashFile(file)
+	if err != nil {
+		return hash, err
+	}
+	if next == hash {
+		return hash, nil
+	}
+
+	config := newViper()
+	config.SetConfigFile(file)
+	values, err := read(config)
+	if err != nil {
+		return hash, err
+	}
+	if err := apply(values); err != nil {
+		return hash, fmt.Errorf("error apply config / %w", err)
+	}
+
+	return next, nil
+}
+
+func setApplied(hash float64) {
+	configHash.Set(hash)
+	reloadSuccessful.Set(1)
+	reloadTimestamp.SetToCurrentTime()
+}
+
+// hashFile hash a file to a metric value, from the first 6 bytes
+// of its md5 sum fitting exactly in a float64
+func hashFile(path string) (float64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error read config %s / %w", path, err)
+	}
+	sum := md5.Sum(b)
+	bytes := make([]byte, 8)
+	copy(bytes[2:], sum[:6])
+	return float64(binary.BigEndian.Uint64(bytes)), nil
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/danztran/telescope/pkg/scope"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -13,6 +14,8 @@ type NodeCache struct {
 	topologyID string
 	scope      scope.Scope
 	log        *zap.SugaredLogger
+	// metric count lookups by result, if set
+	metric *prometheus.CounterVec
 }
 
 func NewNodeCache(topologyID string, scope scope.Scope) *NodeCache {
@@ -28,18 +31,27 @@ func NewNodeCache(topologyID string, scope scope.Scope) *NodeCache {
 
 func (c *NodeCache) Get(ctx context.Context, nodeID string) (*scope.APINode, error) {
 	node := c.GetCache(nodeID)
-	if node == nil {
-		var err error
-		node, err = c.scope.GetNode(ctx, c.topologyID, nodeID)
-		if err != nil {
-			return nil, err
-		}
+	if node != nil {
+		c.observe("hit")
+		return node, nil
+	}
 
+	c.observe("miss")
+	node, err := c.scope.GetNode(ctx, c.topologyID, nodeID)
+	if err != nil {
+		return nil, err
 	}
+	c.Set(*node)
 
 	return node, nil
 }
 
+func (c *NodeCache) observe(result string) {
+	if c.metric != nil {
+		c.metric.WithLabelValues(c.topologyID, result).Inc()
+	}
+}
+
 func (c *NodeCache) GetCache(nodeID string) *scope.APINode {
 	val, ok := c.m.Load(nodeID)
 	if !ok {
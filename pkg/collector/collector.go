@@ -11,6 +11,7 @@ import (
 	"github.com/danztran/telescope/pkg/kube"
 	"github.com/danztran/telescope/pkg/promscope"
 	"github.com/danztran/telescope/pkg/scope"
+	"github.com/danztran/telescope/pkg/shard"
 	"github.com/danztran/telescope/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
@@ -24,6 +25,8 @@ type Deps struct {
 	Kube   kube.Kube
 	Scope  scope.Scope
 	Config Config
+	// Sharder select the nodes collected by the replica, all if nil
+	Sharder shard.Sharder
 }
 
 type Config struct {
@@ -33,18 +36,71 @@ type Config struct {
 	Metrics         Metrics        `mapstructure:"metrics"`
 	ResetInterval   *time.Duration `mapstructure:"reset_interval"`
 	CollectDuration *time.Duration `mapstructure:"collect_duration"`
+	OTLP            OTLP           `mapstructure:"otlp"`
+	// Schedule of collection cycles, every collect_duration
+	Schedule utils.ScheduleConfig `mapstructure:"schedule"`
 }
 
 type Metrics struct {
-	Subsystem string `mapstructure:"subsystem"`
-	Namespace string `mapstructure:"namespace"`
+	Subsystem   string      `mapstructure:"subsystem"`
+	Namespace   string      `mapstructure:"namespace"`
+	RemoteWrite RemoteWrite `mapstructure:"remote_write"`
+	// DurationBuckets are the buckets of the collection duration histogram,
+	// DefaultDurationBuckets if empty
+	DurationBuckets []float64 `mapstructure:"duration_buckets"`
+}
+
+// RemoteWrite push exposed metrics to a remote-write endpoint,
+// for clusters without Prometheus scraping the collector
+type RemoteWrite struct {
+	// URL of the remote-write endpoint, disabled if empty
+	URL      string        `mapstructure:"url"`
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	// BatchSize is the max number of series per request
+	BatchSize int               `mapstructure:"batch_size"`
+	Retry     RemoteWriteRetry  `mapstructure:"retry"`
+	Token     *string           `mapstructure:"token"`
+	Headers   map[string]string `mapstructure:"headers"`
+	// ExternalLabels are added to all series, unless they have these labels
+	ExternalLabels map[string]string `mapstructure:"external_labels"`
+	// Metrics are the pushed metric names, without namespace & subsystem,
+	// edge metrics if empty
+	Metrics []string `mapstructure:"metrics"`
+}
+
+type RemoteWriteRetry struct {
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Backoff     time.Duration `mapstructure:"backoff"`
+}
+
+// Name get the fully-qualified name of a metric registered with these options
+func (m Metrics) Name(name string) string {
+	return prometheus.BuildFQName(m.Namespace, m.Subsystem, name)
+}
+
+// Edge is an exposed connection from a source to a destination port
+type Edge struct {
+	Topology             string `json:"topology"`
+	Source               string `json:"src"`
+	SourceNamespace      string `json:"src_ns"`
+	Destination          string `json:"dest"`
+	DestinationNamespace string `json:"dest_ns"`
+	DestinationPort      string `json:"dest_port"`
+	// SeenAt is the time of the last collection observing the edge
+	SeenAt time.Time `json:"seen_at"`
 }
 
 type Collector interface {
 	Collect(ctx context.Context) error
 	Reset() error
+	GetEdges() []Edge
+	Explain(src string, dest string) []Decision
+	// Reload apply skip patterns, node handlers & intervals of a config
+	Reload(config Config) error
 	RunCollectInterval(ctx context.Context)
 	RunResetInterval(ctx context.Context)
+	RunOTLPInterval(ctx context.Context)
 }
 
 type client struct {
@@ -54,7 +110,18 @@ type client struct {
 	kube           kube.Kube
 	metric         *prometheus.GaugeVec
 	durationMetric *prometheus.HistogramVec
+	metrics        *pipelineMetrics
 	nodeCache      *NodeCache
+	otlp           *otlpExporter
+	sharder        shard.Sharder
+	generation     uint64
+	trail          trail
+	edges          sync.Map
+	reloader       utils.Reloader
+
+	// mx guard the reloadable config & skip patterns
+	mx           sync.RWMutex
+	skipPatterns []*regexp.Regexp
 }
 
 func MustNew(deps Deps) Collector {
@@ -67,6 +134,10 @@ func MustNew(deps Deps) Collector {
 
 func New(deps Deps) (Collector, error) {
 	config := deps.Config
+	skipPatterns, err := compileSkipPatterns(config.SkipPatterns)
+	if err != nil {
+		return nil, err
+	}
 
 	metric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name:      promscope.ConnectionMetric,
@@ -78,22 +149,41 @@ func New(deps Deps) (Collector, error) {
 		return nil, err
 	}
 
+	buckets := config.Metrics.DurationBuckets
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
 	durationMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:      promscope.DurationMetric,
 		Subsystem: config.Metrics.Subsystem,
 		Namespace: config.Metrics.Namespace,
-		Buckets:   []float64{10, 20, 30, 60, 90, 120, 150, 180, 240, 270, 320, 360, 480, 540, 600, 1000},
+		Buckets:   buckets,
 	}, []string{"topology"})
 
 	if err := prometheus.Register(durationMetric); err != nil {
 		return nil, err
 	}
 
+	metrics, err := newPipelineMetrics(config.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
 	if deps.Log == nil {
 		deps.Log = defaultLogger
 	}
 
 	nodeCache := NewNodeCache(config.TopologyID, deps.Scope)
+	nodeCache.metric = metrics.nodeCache
+
+	var otlp *otlpExporter
+	if config.OTLP.Endpoint != "" {
+		exporter, err := newOTLPExporter(config.OTLP)
+		if err != nil {
+			return nil, err
+		}
+		otlp = exporter
+	}
 
 	instance := &client{
 		config:         config,
@@ -102,7 +192,11 @@ func New(deps Deps) (Collector, error) {
 		kube:           deps.Kube,
 		metric:         metric,
 		durationMetric: durationMetric,
+		metrics:        metrics,
 		nodeCache:      nodeCache,
+		otlp:           otlp,
+		sharder:        deps.Sharder,
+		skipPatterns:   skipPatterns,
 	}
 
 	return instance, nil
@@ -122,25 +216,39 @@ func (c *client) Collect(ctx context.Context) error {
 		return err
 	}
 	c.log.Infof("request topology found %d %s", len(topology.Nodes), topologyID)
+	c.trail.start()
+	defer c.trail.done()
+	c.metrics.nodesFetched.WithLabelValues(topologyID).Add(float64(len(topology.Nodes)))
+
+	nodes := c.ownNodes(topology.Nodes)
+
+	c.mx.RLock()
+	handlers := c.config.MaxNodeHandlers
+	c.mx.RUnlock()
+	if handlers == 0 {
+		handlers = 1
+	}
 
-	nodeChan := make(chan scope.NodeSummary, c.config.MaxNodeHandlers)
+	nodeChan := make(chan scope.NodeSummary, handlers)
 	go func() {
 		defer close(nodeChan)
-		for _, nodeSummary := range topology.Nodes {
-			nodeChan <- nodeSummary
+		for _, nodeSummary := range nodes {
+			select {
+			case nodeChan <- nodeSummary:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
 	wg := sync.WaitGroup{}
-	defer wg.Wait()
-
-	for i := uint(0); i < c.config.MaxNodeHandlers; i++ {
+	for i := uint(0); i < handlers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for nodeSummary := range nodeChan {
 				if ctx.Err() != nil {
-					break
+					continue
 				}
 				err := c.ExposeNodeMetrics(ctx, nodeSummary)
 				if err != nil {
@@ -149,8 +257,40 @@ func (c *client) Collect(ctx context.Context) error {
 			}
 		}()
 	}
+	wg.Wait()
 
-	return nil
+	return ctx.Err()
+}
+
+// ownNodes get the nodes of the replica shard. Edges are reset when
+// the shard members change, nodes moving to other replicas.
+func (c *client) ownNodes(nodes scope.NodeSummaries) []scope.NodeSummary {
+	owned := make([]scope.NodeSummary, 0, len(nodes))
+	if c.sharder == nil {
+		for _, node := range nodes {
+			owned = append(owned, node)
+		}
+		return owned
+	}
+
+	if generation := c.sharder.Generation(); generation != c.generation {
+		if c.generation != 0 {
+			c.log.Infof("reset edges on shard rebalance: %v", c.sharder.Members())
+			if err := c.Reset(); err != nil {
+				c.log.Error(err)
+			}
+		}
+		c.generation = generation
+	}
+
+	for id, node := range nodes {
+		if c.sharder.Owns(id) {
+			owned = append(owned, node)
+		}
+	}
+	c.log.Infof("collecting %d/%d nodes of shard", len(owned), len(nodes))
+
+	return owned
 }
 
 func (c *client) ExposeNodeMetrics(ctx context.Context, nodeSummary scope.NodeSummary) error {
@@ -164,51 +304,66 @@ func (c *client) ExposeNodeMetrics(ctx context.Context, nodeSummary scope.NodeSu
 		return err
 	}
 
-	valid, err := c.IsValidLabels(*srcNode)
-	if err != nil || !valid {
-		return err
+	connections := getOutgoingConnections(*srcNode)
+	dropAll := func(reason string, rule string) {
+		for _, conn := range connections {
+			c.decide(newDecision(*srcNode, conn), reason, rule)
+		}
+	}
+
+	if pattern := c.matchSkipPattern(*srcNode); pattern != "" {
+		dropAll(DropSkipPattern, pattern)
+		return nil
 	}
 
 	srcObject, err := c.GetRootObjectByNode(*srcNode)
 	if err != nil {
+		dropAll(dropReason(err), err.Error())
 		c.log.Warn(err)
 		return nil
 	}
 
-	connections := getOutgoingConnections(*srcNode)
 	if connections == nil {
 		c.log.Warnf("not found connections: %s", nodeSummary.ID)
 		return nil
 	}
 
 	for _, conn := range connections {
+		decision := newDecision(*srcNode, conn)
+		decision.SourceObject = objectName(srcObject)
+
 		destNode, err := c.nodeCache.Get(ctx, conn.NodeID)
 		if err != nil {
+			c.decide(decision, dropReason(err), err.Error())
 			if utils.IsErrNotFound(err) {
 				c.log.Warnf("not found node: %s", conn.NodeID)
-				return nil
 			}
 			continue
 		}
+		decision.DestinationPodUID = getPodUID(*destNode)
 
-		valid, err := c.IsValidLabels(*destNode)
-		if err != nil || !valid {
+		if pattern := c.matchSkipPattern(*destNode); pattern != "" {
+			c.decide(decision, DropSkipPattern, pattern)
 			continue
 		}
 
 		destObject, err := c.GetRootObjectByNode(*destNode)
 		if err != nil {
+			c.decide(decision, dropReason(err), err.Error())
 			c.log.Warn(err)
 			continue
 		}
+		decision.DestinationObject = objectName(destObject)
 
 		// destPort := c.GetConnectionPort(conn)
 		destPort := getConnectionPort(conn)
 		ports, err := c.GetPodExposePorts(*destNode)
 		if err != nil {
+			c.decide(decision, dropReason(err), err.Error())
 			c.log.Warn(err)
 			continue
 		}
+		decision.Ports = ports
 
 		validPort := func() bool {
 			if len(ports) == 0 {
@@ -223,6 +378,7 @@ func (c *client) ExposeNodeMetrics(ctx context.Context, nodeSummary scope.NodeSu
 		}()
 
 		if !validPort {
+			c.decide(decision, DropPortMismatch, "")
 			c.log.Debugf(
 				`ignored connection from "%s" to "%s": dest port "%s" not found in pod: %+v`,
 				nodeSummary.Label, conn.Label, destPort, ports)
@@ -238,7 +394,20 @@ func (c *client) ExposeNodeMetrics(ctx context.Context, nodeSummary scope.NodeSu
 			"dest_port": destPort,
 		}
 
-		c.metric.With(labels)
+		if !c.config.OTLP.Exclusive {
+			c.metric.With(labels)
+		}
+		c.edges.Store(fmt.Sprint(labels), Edge{
+			Topology:             labels["topology"],
+			Source:               labels["src"],
+			SourceNamespace:      labels["src_ns"],
+			Destination:          labels["dest"],
+			DestinationNamespace: labels["dest_ns"],
+			DestinationPort:      labels["dest_port"],
+			SeenAt:               time.Now(),
+		})
+		c.metrics.edgesEmitted.WithLabelValues(c.config.TopologyID).Inc()
+		c.decide(decision, DecisionAccepted, "")
 		c.log.Infof("exposed metric %s: %v", promscope.ConnectionMetric, labels)
 	}
 
@@ -248,12 +417,13 @@ func (c *client) ExposeNodeMetrics(ctx context.Context, nodeSummary scope.NodeSu
 func (c *client) GetRootObjectByNode(node scope.APINode) (meta.Object, error) {
 	podUID := getPodUID(node)
 	if podUID == "" {
-		return nil, fmt.Errorf(`not found pod uid: node_label="%s"`, node.Node.Label)
+		return nil, fmt.Errorf(`%w: node_label="%s"`, errMissingPodUID, node.Node.Label)
 	}
 
 	rootObject := c.kube.GetRootObject(podUID)
+	c.metrics.kubeLookups.WithLabelValues("root_object", lookupResult(rootObject != nil, nil)).Inc()
 	if rootObject == nil {
-		return nil, fmt.Errorf(`not found root object by uid="%s": node_label="%s"`, podUID, node.Node.Label)
+		return nil, fmt.Errorf(`%w by uid="%s": node_label="%s"`, errMissingRootObject, podUID, node.Node.Label)
 	}
 
 	return rootObject, nil
@@ -262,11 +432,13 @@ func (c *client) GetRootObjectByNode(node scope.APINode) (meta.Object, error) {
 func (c *client) GetPodExposePorts(node scope.APINode) ([]string, error) {
 	podUID := getPodUID(node)
 	if podUID == "" {
-		return nil, fmt.Errorf(`not found pod uid: node_label="%s"`, node.Node.Label)
+		return nil, fmt.Errorf(`%w: node_label="%s"`, errMissingPodUID, node.Node.Label)
 	}
 
 	pod, err := c.kube.GetPod(podUID)
+	c.metrics.kubeLookups.WithLabelValues("pod", lookupResult(pod != nil, err)).Inc()
 	if pod == nil || err != nil {
+		// a pod missing from the store exposes no known port, so that any port is accepted
 		return nil, err
 	}
 
@@ -280,47 +452,145 @@ func (c *client) GetPodExposePorts(node scope.APINode) ([]string, error) {
 	return ports, nil
 }
 
-func (c *client) IsValidLabels(node scope.APINode) (bool, error) {
-	for _, pattern := range c.config.SkipPatterns {
-		matched, err := regexp.MatchString(pattern, node.Node.Label)
+// matchSkipPattern get the first skip pattern matching the node label, if any
+func (c *client) matchSkipPattern(node scope.APINode) string {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	for _, pattern := range c.skipPatterns {
+		if pattern.MatchString(node.Node.Label) {
+			c.log.Debugf(`ignored node: skip_pattern="%s" label="%s"`, pattern, node.Node.Label)
+			return pattern.String()
+		}
+	}
+
+	return ""
+}
+
+func compileSkipPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
 		if err != nil {
-			return false, err
+			return nil, fmt.Errorf("invalid skip pattern %s / %w", pattern, err)
 		}
-		if matched {
-			c.log.Debugf(`ignored node: skip_pattern="%s" label="%s"`, pattern, node.Node.Label)
-			return false, nil
+		compiled[i] = re
+	}
+	return compiled, nil
+}
+
+// Validate check skip patterns, node handlers & intervals
+func (config Config) Validate() error {
+	if _, err := compileSkipPatterns(config.SkipPatterns); err != nil {
+		return err
+	}
+	if config.MaxNodeHandlers == 0 {
+		return fmt.Errorf("max_node_handlers must be positive")
+	}
+	if config.ResetInterval != nil && *config.ResetInterval <= 0 {
+		return fmt.Errorf("reset_interval must be positive: %v", *config.ResetInterval)
+	}
+	if config.CollectDuration != nil {
+		schedule := utils.Schedule{
+			ScheduleConfig: config.Schedule,
+			Interval:       *config.CollectDuration,
+		}
+		if err := schedule.Validate(); err != nil {
+			return fmt.Errorf("invalid collect schedule / %w", err)
 		}
 	}
+	return nil
+}
 
-	return true, nil
+// Reload apply skip patterns, node handlers & intervals of a config,
+// restarting interval jobs once their running cycle completes.
+// Other settings require a restart.
+func (c *client) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	skipPatterns, err := compileSkipPatterns(config.SkipPatterns)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	c.skipPatterns = skipPatterns
+	c.config.SkipPatterns = config.SkipPatterns
+	c.config.MaxNodeHandlers = config.MaxNodeHandlers
+	c.config.ResetInterval = config.ResetInterval
+	c.config.CollectDuration = config.CollectDuration
+	c.config.Schedule = config.Schedule
+	c.mx.Unlock()
+
+	c.reloader.Reload()
+	return nil
 }
 
 func (c *client) Reset() error {
 	c.metric.Reset()
+	c.edges.Range(func(key interface{}, _ interface{}) bool {
+		c.edges.Delete(key)
+		return true
+	})
 	return nil
 }
 
+// GetEdges get the exposed edges since the last reset
+func (c *client) GetEdges() []Edge {
+	edges := []Edge{}
+	c.edges.Range(func(_ interface{}, val interface{}) bool {
+		edges = append(edges, val.(Edge))
+		return true
+	})
+	return edges
+}
+
 func (c *client) RunResetInterval(ctx context.Context) {
-	if c.config.ResetInterval == nil {
-		c.log.Info("disabled resetting interval")
-		return
-	}
+	load := func() (utils.Schedule, bool) {
+		c.mx.RLock()
+		defer c.mx.RUnlock()
 
-	utils.RunStateless(ctx, *c.config.ResetInterval, func() {
+		if c.config.ResetInterval == nil {
+			c.log.Info("disabled resetting interval")
+			return utils.Schedule{}, false
+		}
+		schedule := utils.Schedule{
+			Name:     "reset",
+			Interval: *c.config.ResetInterval,
+		}
+		schedule.Mode = utils.ScheduleFixedRate
+		return schedule, true
+	}
+	err := c.reloader.RunSchedule(ctx, load, func(ctx context.Context) error {
 		err := c.Reset()
 		if err != nil {
 			c.log.Error(err)
 		}
+		return err
 	})
+	if err != nil {
+		c.log.Errorf("error run reset interval / %s", err)
+	}
 }
 
 func (c *client) RunCollectInterval(ctx context.Context) {
-	if c.config.CollectDuration == nil {
-		c.log.Info("disabled collecting interval")
-		return
-	}
+	load := func() (utils.Schedule, bool) {
+		c.mx.RLock()
+		defer c.mx.RUnlock()
 
-	utils.RunStateful(ctx, *c.config.CollectDuration, func() {
+		if c.config.CollectDuration == nil {
+			c.log.Info("disabled collecting interval")
+			return utils.Schedule{}, false
+		}
+		return utils.Schedule{
+			ScheduleConfig: c.config.Schedule,
+			Name:           "collect",
+			Interval:       *c.config.CollectDuration,
+			Immediate:      true,
+		}, true
+	}
+	err := c.reloader.RunSchedule(ctx, load, func(ctx context.Context) error {
 		topologyID := c.config.TopologyID
 		defer utils.LogDuration()(c.log, "collecting topology %s", topologyID)
 
@@ -329,5 +599,9 @@ func (c *client) RunCollectInterval(ctx context.Context) {
 		if err != nil {
 			c.log.Error(err)
 		}
+		return err
 	})
+	if err != nil {
+		c.log.Errorf("error run collecting interval / %s", err)
+	}
 }
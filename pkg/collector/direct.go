@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+// direct is a mapnode MetricsClient reading edges exposed by an in-process
+// collector, without any TSDB round trip. Connections are the edges seen
+// since the window start, or since the last reset if it is later: older
+// edges are forgotten. The collector observes its own cluster only,
+// so connections have no cluster.
+type direct struct {
+	collector Collector
+}
+
+// NewDirect create a MetricsClient reading edges of a collector
+func NewDirect(c Collector) mapnode.MetricsClient {
+	return &direct{collector: c}
+}
+
+func (d *direct) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
+	edges := d.collector.GetEdges()
+	connections := make([]mapnode.Connection, 0, len(edges))
+	for _, e := range edges {
+		if e.SeenAt.Before(start) {
+			continue
+		}
+		connections = append(connections, mapnode.Connection{
+			Source:               e.Source,
+			SourceNamespace:      e.SourceNamespace,
+			Destination:          e.Destination,
+			DestinationNamespace: e.DestinationNamespace,
+			DestinationPort:      e.DestinationPort,
+		})
+	}
+
+	return connections, nil
+}
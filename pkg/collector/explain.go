@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danztran/telescope/pkg/scope"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DecisionAccepted is the reason of exposed edges
+const DecisionAccepted = "accepted"
+
+// Decision is the trail of a candidate connection in a collection cycle,
+// from Scope nodes to the exposed edge or the reason it is dropped
+type Decision struct {
+	Source            string   `json:"src"`
+	SourceNodeID      string   `json:"src_node_id"`
+	SourcePodUID      string   `json:"src_pod_uid,omitempty"`
+	SourceObject      string   `json:"src_object,omitempty"`
+	Destination       string   `json:"dest"`
+	DestinationNodeID string   `json:"dest_node_id"`
+	DestinationPodUID string   `json:"dest_pod_uid,omitempty"`
+	DestinationObject string   `json:"dest_object,omitempty"`
+	DestinationPort   string   `json:"dest_port"`
+	Ports             []string `json:"ports,omitempty"`
+	Accepted          bool     `json:"accepted"`
+	// Reason is DecisionAccepted or the drop reason
+	Reason string `json:"reason"`
+	// Rule is the matched skip pattern or the error dropping the connection
+	Rule string    `json:"rule,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Match check if the decision is from src to dest, matching node labels,
+// object names or namespace/name. Empty values match any node.
+func (d Decision) Match(src string, dest string) bool {
+	return matchNode(src, d.Source, d.SourceObject) && matchNode(dest, d.Destination, d.DestinationObject)
+}
+
+func matchNode(query string, label string, object string) bool {
+	if query == "" || query == label || query == object {
+		return true
+	}
+	i := strings.LastIndex(object, "/")
+	return i >= 0 && query == object[i+1:]
+}
+
+// trail record decisions of the running cycle, and keep the last complete one
+type trail struct {
+	mx      sync.Mutex
+	current []Decision
+	last    []Decision
+}
+
+func (t *trail) start() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.current = []Decision{}
+}
+
+func (t *trail) done() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.last, t.current = t.current, nil
+}
+
+func (t *trail) record(d Decision) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.current != nil {
+		t.current = append(t.current, d)
+	}
+}
+
+// get decisions of the last complete cycle, or the running one if none
+func (t *trail) get() []Decision {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.last == nil {
+		return append([]Decision{}, t.current...)
+	}
+	return append([]Decision{}, t.last...)
+}
+
+// newDecision start the decision of a connection from a source node
+func newDecision(src scope.APINode, conn scope.Connection) Decision {
+	return Decision{
+		Source:            src.Node.Label,
+		SourceNodeID:      src.Node.ID,
+		SourcePodUID:      getPodUID(src),
+		Destination:       conn.Label,
+		DestinationNodeID: conn.NodeID,
+		DestinationPort:   getConnectionPort(conn),
+	}
+}
+
+func objectName(object meta.Object) string {
+	return object.GetNamespace() + "/" + object.GetName()
+}
+
+// decide record a decision with its reason, dropped unless accepted
+func (c *client) decide(d Decision, reason string, rule string) {
+	if reason != DecisionAccepted {
+		c.metrics.edgesDropped.WithLabelValues(c.config.TopologyID, reason).Inc()
+	}
+	d.Accepted = reason == DecisionAccepted
+	d.Reason = reason
+	d.Rule = rule
+	d.Time = time.Now()
+	c.trail.record(d)
+}
+
+// Explain get decisions of the last collection cycle from src to dest
+func (c *client) Explain(src string, dest string) []Decision {
+	decisions := []Decision{}
+	for _, d := range c.trail.get() {
+		if d.Match(src, dest) {
+			decisions = append(decisions, d)
+		}
+	}
+	return decisions
+}
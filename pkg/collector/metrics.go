@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"errors"
+
+	"github.com/danztran/telescope/pkg/promscope"
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultDurationBuckets are the buckets of the collection duration histogram
+var DefaultDurationBuckets = []float64{1, 2.5, 5, 10, 20, 30, 60, 90, 120, 150, 180, 240, 270, 320, 360, 480, 540, 600, 1000}
+
+// reasons of dropped edges
+const (
+	DropNodeNotFound      = "node_not_found"
+	DropNodeError         = "node_error"
+	DropSkipPattern       = "skip_pattern"
+	DropMissingPodUID     = "missing_pod_uid"
+	DropMissingRootObject = "missing_root_object"
+	DropPortMismatch      = "port_mismatch"
+)
+
+var (
+	errMissingPodUID     = errors.New("not found pod uid")
+	errMissingRootObject = errors.New("not found root object")
+)
+
+// pipelineMetrics instrument the steps of a collection cycle
+type pipelineMetrics struct {
+	nodesFetched *prometheus.CounterVec
+	nodeCache    *prometheus.CounterVec
+	edgesEmitted *prometheus.CounterVec
+	edgesDropped *prometheus.CounterVec
+	kubeLookups  *prometheus.CounterVec
+}
+
+func newPipelineMetrics(config Metrics) (*pipelineMetrics, error) {
+	counter := func(name string, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:      name,
+			Subsystem: config.Subsystem,
+			Namespace: config.Namespace,
+			Help:      help,
+		}, labels)
+	}
+
+	m := &pipelineMetrics{
+		nodesFetched: counter(promscope.NodesFetchedMetric,
+			"Nodes of the topology fetched from Scope.", "topology"),
+		nodeCache: counter(promscope.NodeCacheMetric,
+			"Node lookups of the collection cycle cache, by result.", "topology", "result"),
+		edgesEmitted: counter(promscope.EdgesEmittedMetric,
+			"Edges exposed by the collector.", "topology"),
+		edgesDropped: counter(promscope.EdgesDroppedMetric,
+			"Candidate edges dropped by the collector, by reason.", "topology", "reason"),
+		kubeLookups: counter(promscope.KubeLookupsMetric,
+			"Lookups of the kube store, by kind & result.", "kind", "result"),
+	}
+
+	for _, c := range []prometheus.Collector{m.nodesFetched, m.nodeCache, m.edgesEmitted, m.edgesDropped, m.kubeLookups} {
+		if err := prometheus.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// dropReason get the reason of an edge dropped on error
+func dropReason(err error) string {
+	switch {
+	case utils.IsErrNotFound(err):
+		return DropNodeNotFound
+	case errors.Is(err, errMissingPodUID):
+		return DropMissingPodUID
+	case errors.Is(err, errMissingRootObject):
+		return DropMissingRootObject
+	default:
+		return DropNodeError
+	}
+}
+
+// lookupResult get the result label of a kube store lookup
+func lookupResult(found bool, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case !found:
+		return "not_found"
+	default:
+		return "found"
+	}
+}
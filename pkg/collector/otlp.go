@@ -0,0 +1,223 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danztran/telescope/pkg/httpclient"
+	"github.com/danztran/telescope/pkg/utils"
+)
+
+const (
+	defaultOTLPInterval = time.Minute
+	defaultOTLPTimeout  = 10 * time.Second
+	// DefaultOTLPMetric is the name of the exported edge gauge
+	DefaultOTLPMetric = "service_graph_connection"
+	otlpScope         = "github.com/danztran/telescope/pkg/collector"
+)
+
+// OTLP export edges as OpenTelemetry metrics to an OTLP/HTTP receiver,
+// following the service graph conventions
+type OTLP struct {
+	// Endpoint of the receiver, e.g. http://otel-collector:4318, disabled if empty
+	Endpoint string            `mapstructure:"endpoint"`
+	Interval time.Duration     `mapstructure:"interval"`
+	Timeout  time.Duration     `mapstructure:"timeout"`
+	Headers  map[string]string `mapstructure:"headers"`
+	// Metric is the name of the edge gauge, DefaultOTLPMetric if empty
+	Metric             string            `mapstructure:"metric"`
+	ResourceAttributes map[string]string `mapstructure:"resource_attributes"`
+	// Exclusive stop exposing edges in the Prometheus connection metric
+	Exclusive bool `mapstructure:"exclusive"`
+}
+
+// otlpExporter post edges in the OTLP/HTTP JSON encoding
+type otlpExporter struct {
+	config   OTLP
+	client   httpclient.Client
+	resource []otlpAttribute
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt"`
+}
+
+type otlpMetric struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Unit        string `json:"unit"`
+	Gauge       struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	} `json:"gauge"`
+}
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+func newOTLPExporter(config OTLP) (*otlpExporter, error) {
+	if config.Interval <= 0 {
+		config.Interval = defaultOTLPInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultOTLPTimeout
+	}
+	if config.Metric == "" {
+		config.Metric = DefaultOTLPMetric
+	}
+
+	address := strings.TrimSuffix(strings.TrimRight(config.Endpoint, "/"), "/v1/metrics")
+	client, err := httpclient.NewClient(httpclient.Config{
+		Address: address,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp endpoint / %w", err)
+	}
+
+	resource := map[string]string{
+		"service.name": "telescope",
+	}
+	for k, v := range config.ResourceAttributes {
+		resource[k] = v
+	}
+
+	e := &otlpExporter{
+		config:   config,
+		client:   client,
+		resource: otlpAttributes(resource),
+	}
+
+	return e, nil
+}
+
+// export post edges as data points of the edge gauge
+func (e *otlpExporter) export(ctx context.Context, edges []Edge, start time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	startNano := strconv.FormatInt(start.UnixNano(), 10)
+	nowNano := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	metric := otlpMetric{
+		Name:        e.config.Metric,
+		Description: "Connections from client to server services, observed by the collector.",
+		Unit:        "1",
+	}
+	metric.Gauge.DataPoints = make([]otlpDataPoint, len(edges))
+	for i, edge := range edges {
+		metric.Gauge.DataPoints[i] = otlpDataPoint{
+			Attributes: otlpAttributes(map[string]string{
+				"client":           edge.Source,
+				"client_namespace": edge.SourceNamespace,
+				"server":           edge.Destination,
+				"server_namespace": edge.DestinationNamespace,
+				"server_port":      edge.DestinationPort,
+				"topology":         edge.Topology,
+				// empty for direct connections, as in service graphs
+				"connection_type": "",
+			}),
+			StartTimeUnixNano: startNano,
+			TimeUnixNano:      nowNano,
+			AsInt:             "1",
+		}
+	}
+
+	scope := otlpScopeMetrics{Metrics: []otlpMetric{metric}}
+	scope.Scope.Name = otlpScope
+	resource := otlpResourceMetrics{ScopeMetrics: []otlpScopeMetrics{scope}}
+	resource.Resource.Attributes = e.resource
+
+	body, err := json.Marshal(otlpRequest{ResourceMetrics: []otlpResourceMetrics{resource}})
+	if err != nil {
+		return fmt.Errorf("error marshal otlp request / %w", err)
+	}
+
+	url := e.client.URL("/v1/metrics", nil)
+	req, err := http.NewRequest(http.MethodPost, url.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error create new request / %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	_, _, err = e.client.Do(ctx, req, nil)
+	if err != nil {
+		return fmt.Errorf("error export %d edges to %s / %w", len(edges), url, err)
+	}
+
+	return nil
+}
+
+// otlpAttributes convert a map to attributes sorted by key
+func otlpAttributes(m map[string]string) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(m))
+	for k, v := range m {
+		attrs = append(attrs, otlpAttribute{Key: k, Value: otlpAnyValue{StringValue: v}})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+	return attrs
+}
+
+// RunOTLPInterval export edges to the OTLP receiver every interval
+func (c *client) RunOTLPInterval(ctx context.Context) {
+	if c.otlp == nil {
+		c.log.Info("disabled otlp export: no endpoint")
+		return
+	}
+
+	start := time.Now()
+	schedule := utils.Schedule{
+		Name:     "otlp_export",
+		Interval: c.otlp.config.Interval,
+	}
+	err := utils.RunSchedule(ctx, schedule, func(ctx context.Context) error {
+		edges := c.GetEdges()
+		err := c.otlp.export(ctx, edges, start)
+		if err != nil {
+			c.log.Error(err)
+			return err
+		}
+		c.log.Debugf("exported %d edges to otlp", len(edges))
+		return nil
+	})
+	if err != nil {
+		c.log.Errorf("error run otlp export interval / %s", err)
+	}
+}
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// otlpPayload is the decoded OTLP/HTTP JSON of an export request,
+// independent of the exporter types
+type otlpPayload struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []otlpPayloadAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			Metrics []struct {
+				Name  string `json:"name"`
+				Gauge struct {
+					DataPoints []struct {
+						Attributes        []otlpPayloadAttribute `json:"attributes"`
+						StartTimeUnixNano string                 `json:"startTimeUnixNano"`
+						TimeUnixNano      string                 `json:"timeUnixNano"`
+						AsInt             string                 `json:"asInt"`
+					} `json:"dataPoints"`
+				} `json:"gauge"`
+			} `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+type otlpPayloadAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func attributeMap(t *testing.T, attrs []otlpPayloadAttribute) map[string]string {
+	t.Helper()
+	m := map[string]string{}
+	for _, attr := range attrs {
+		if attr.Value.StringValue == nil {
+			t.Errorf("got attribute %s without string value", attr.Key)
+			continue
+		}
+		m[attr.Key] = *attr.Value.StringValue
+	}
+	return m
+}
+
+func TestOTLPExport(t *testing.T) {
+	var (
+		path    string
+		headers http.Header
+		body    []byte
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		headers = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer receiver.Close()
+
+	e, err := newOTLPExporter(OTLP{
+		Endpoint:           receiver.URL + "/v1/metrics",
+		Headers:            map[string]string{"Authorization": "Basic token"},
+		ResourceAttributes: map[string]string{"k8s.cluster.name": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-time.Minute)
+	edges := []Edge{{
+		Topology:             "containers",
+		Source:               "api",
+		SourceNamespace:      "payments",
+		Destination:          "db",
+		DestinationNamespace: "billing",
+		DestinationPort:      "5432",
+	}}
+	if err := e.export(context.Background(), edges, start); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/v1/metrics" {
+		t.Errorf("got path %s, want /v1/metrics", path)
+	}
+	if got := headers.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got content type %q", got)
+	}
+	if got := headers.Get("Authorization"); got != "Basic token" {
+		t.Errorf("got authorization %q", got)
+	}
+
+	var payload otlpPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.ResourceMetrics) != 1 || len(payload.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("got payload %s", body)
+	}
+
+	resource := attributeMap(t, payload.ResourceMetrics[0].Resource.Attributes)
+	wantResource := map[string]string{"service.name": "telescope", "k8s.cluster.name": "prod"}
+	if len(resource) != len(wantResource) {
+		t.Errorf("got resource attributes %v, want %v", resource, wantResource)
+	}
+	for k, v := range wantResource {
+		if resource[k] != v {
+			t.Errorf("got resource attribute %s %q, want %q", k, resource[k], v)
+		}
+	}
+
+	scope := payload.ResourceMetrics[0].ScopeMetrics[0]
+	if scope.Scope.Name != otlpScope {
+		t.Errorf("got scope %s", scope.Scope.Name)
+	}
+	if len(scope.Metrics) != 1 || scope.Metrics[0].Name != DefaultOTLPMetric {
+		t.Fatalf("got metrics %+v, want %s", scope.Metrics, DefaultOTLPMetric)
+	}
+
+	points := scope.Metrics[0].Gauge.DataPoints
+	if len(points) != 1 {
+		t.Fatalf("got %d data points, want 1", len(points))
+	}
+	point := points[0]
+	if point.AsInt != "1" || point.StartTimeUnixNano == "" || point.TimeUnixNano == "" {
+		t.Errorf("got data point %+v", point)
+	}
+
+	attrs := attributeMap(t, point.Attributes)
+	wantAttrs := map[string]string{
+		"client":           "api",
+		"client_namespace": "payments",
+		"server":           "db",
+		"server_namespace": "billing",
+		"server_port":      "5432",
+		"topology":         "containers",
+		"connection_type":  "",
+	}
+	if len(attrs) != len(wantAttrs) {
+		t.Errorf("got attributes %v, want %v", attrs, wantAttrs)
+	}
+	for k, v := range wantAttrs {
+		if got, ok := attrs[k]; !ok || got != v {
+			t.Errorf("got attribute %s %q, want %q", k, got, v)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package election elect a leader among telescope replicas with a Kubernetes
+// Lease, so that only the leader collects from Scope.
+package election
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+var defaultLogger = utils.MustGetLogger("election")
+
+// roles of replicas
+const (
+	RoleLeader   string = "leader"
+	RoleFollower string = "follower"
+)
+
+const (
+	defaultLeaseName     = "telescope"
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+type Deps struct {
+	Log *zap.SugaredLogger
+	// Client of the Lease API, required if enabled
+	Client kubernetes.Interface
+	Config Config
+}
+
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// LeaseName & LeaseNamespace locate the Lease, the namespace being
+	// POD_NAMESPACE or default if empty
+	LeaseName      string `mapstructure:"lease_name"`
+	LeaseNamespace string `mapstructure:"lease_namespace"`
+	// Identity of the replica, POD_NAME or the hostname if empty
+	Identity      string        `mapstructure:"identity"`
+	LeaseDuration time.Duration `mapstructure:"lease_duration"`
+	RenewDeadline time.Duration `mapstructure:"renew_deadline"`
+	RetryPeriod   time.Duration `mapstructure:"retry_period"`
+}
+
+type Elector interface {
+	// Run run jobs while leading, until the context is done.
+	// Jobs are cancelled and waited for when the leadership is lost,
+	// and run again when it is regained.
+	Run(ctx context.Context, jobs ...func(context.Context))
+	IsLeader() bool
+	Role() string
+	Identity() string
+}
+
+type elector struct {
+	config Config
+	log    *zap.SugaredLogger
+	client kubernetes.Interface
+	metric *prometheus.GaugeVec
+
+	mx     sync.RWMutex
+	leader bool
+	// termMx is held while running jobs of a leadership term,
+	// so that terms never overlap
+	termMx sync.Mutex
+}
+
+func MustNew(deps Deps) Elector {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (Elector, error) {
+	config := deps.Config
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	if config.Enabled && deps.Client == nil {
+		return nil, fmt.Errorf("kube client is required")
+	}
+	if config.LeaseName == "" {
+		config.LeaseName = defaultLeaseName
+	}
+	if config.LeaseNamespace == "" {
+		config.LeaseNamespace = os.Getenv("POD_NAMESPACE")
+	}
+	if config.LeaseNamespace == "" {
+		config.LeaseNamespace = meta.NamespaceDefault
+	}
+	if config.Identity == "" {
+		config.Identity = os.Getenv("POD_NAME")
+	}
+	if config.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error get hostname / %w", err)
+		}
+		config.Identity = hostname
+	}
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = defaultLeaseDuration
+	}
+	if config.RenewDeadline <= 0 {
+		config.RenewDeadline = defaultRenewDeadline
+	}
+	if config.RetryPeriod <= 0 {
+		config.RetryPeriod = defaultRetryPeriod
+	}
+
+	metric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "leader_election_is_leader",
+		Help: "Whether the replica is the leader collecting from Scope.",
+	}, []string{"lease", "identity"})
+
+	if err := prometheus.Register(metric); err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		metric = are.ExistingCollector.(*prometheus.GaugeVec)
+	}
+
+	e := &elector{
+		config: config,
+		log:    deps.Log,
+		client: deps.Client,
+		metric: metric,
+		// replicas lead alone without election
+		leader: !config.Enabled,
+	}
+	e.setLeader(e.leader)
+
+	return e, nil
+}
+
+func (e *elector) Run(ctx context.Context, jobs ...func(context.Context)) {
+	if !e.config.Enabled {
+		e.log.Info("disabled leader election: leading alone")
+		runJobs(ctx, jobs)
+		return
+	}
+
+	// the elector returns when the leadership is lost, so run a new one:
+	// a previous elector may still be renewing its lock in the background
+	for ctx.Err() == nil {
+		le, err := e.newLeaderElector(jobs)
+		if err != nil {
+			e.log.Errorf("error create leader elector / %s", err)
+			return
+		}
+		le.Run(ctx)
+	}
+
+	// wait for jobs of the last term
+	e.termMx.Lock()
+	defer e.termMx.Unlock()
+}
+
+// newLeaderElector create an elector of the lease, running jobs while leading
+func (e *elector) newLeaderElector(jobs []func(context.Context)) (*leaderelection.LeaderElector, error) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: meta.ObjectMeta{
+			Name:      e.config.LeaseName,
+			Namespace: e.config.LeaseNamespace,
+		},
+		Client: e.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: e.config.Identity,
+		},
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            e.config.LeaseName,
+		LeaseDuration:   e.config.LeaseDuration,
+		RenewDeadline:   e.config.RenewDeadline,
+		RetryPeriod:     e.config.RetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				e.termMx.Lock()
+				defer e.termMx.Unlock()
+				if ctx.Err() != nil {
+					return
+				}
+				e.log.Infof("started leading lease %s/%s as %s",
+					e.config.LeaseNamespace, e.config.LeaseName, e.config.Identity)
+				e.setLeader(true)
+				runJobs(ctx, jobs)
+			},
+			OnStoppedLeading: func() {
+				e.log.Infof("stopped leading lease %s/%s", e.config.LeaseNamespace, e.config.LeaseName)
+				e.setLeader(false)
+			},
+			OnNewLeader: func(identity string) {
+				e.log.Infof("new leader of lease %s/%s: %s", e.config.LeaseNamespace, e.config.LeaseName, identity)
+			},
+		},
+	})
+}
+
+// runJobs run jobs until they all return
+func runJobs(ctx context.Context, jobs []func(context.Context)) {
+	wg := sync.WaitGroup{}
+	utils.RunJobsWithContext(ctx, &wg, jobs...)
+	wg.Wait()
+}
+
+func (e *elector) setLeader(leader bool) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.leader = leader
+	value := 0.0
+	if leader {
+		value = 1
+	}
+	e.metric.WithLabelValues(e.config.LeaseName, e.config.Identity).Set(value)
+}
+
+func (e *elector) IsLeader() bool {
+	e.mx.RLock()
+	defer e.mx.RUnlock()
+	return e.leader
+}
+
+func (e *elector) Role() string {
+	if e.IsLeader() {
+		return RoleLeader
+	}
+	return RoleFollower
+}
+
+func (e *elector) Identity() string {
+	return e.config.Identity
+}
@@ -0,0 +1,174 @@
+package election
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordination "k8s.io/api/coordination/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testTimeout = 10 * time.Second
+
+func newTestElector(t *testing.T, client kubernetes.Interface, identity string) Elector {
+	t.Helper()
+	e, err := New(Deps{
+		Client: client,
+		Config: Config{
+			Enabled:        true,
+			LeaseName:      "telescope",
+			LeaseNamespace: "monitoring",
+			Identity:       identity,
+			LeaseDuration:  600 * time.Millisecond,
+			RenewDeadline:  400 * time.Millisecond,
+			RetryPeriod:    100 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// term run an elector with a job signalling its leadership terms,
+// it returns the started terms and the done ones
+func term(ctx context.Context, e Elector) (<-chan struct{}, <-chan struct{}, <-chan struct{}) {
+	started := make(chan struct{}, 10)
+	stopped := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(ctx context.Context) {
+			started <- struct{}{}
+			<-ctx.Done()
+			stopped <- struct{}{}
+		})
+	}()
+	return started, stopped, done
+}
+
+func wait(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(testTimeout):
+		t.Fatalf("timeout waiting for %s", what)
+	}
+}
+
+func holder(t *testing.T, client kubernetes.Interface) string {
+	t.Helper()
+	lease, err := client.CoordinationV1().Leases("monitoring").Get("telescope", meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func TestAcquire(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	e := newTestElector(t, client, "replica-a")
+	if e.IsLeader() || e.Role() != RoleFollower {
+		t.Fatal("got leader before the election")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started, stopped, done := term(ctx, e)
+	wait(t, started, "leading")
+
+	if !e.IsLeader() || e.Role() != RoleLeader {
+		t.Error("got follower after acquiring the lease")
+	}
+	if got := holder(t, client); got != "replica-a" {
+		t.Errorf("got lease holder %q, want replica-a", got)
+	}
+
+	cancel()
+	wait(t, stopped, "jobs stopped")
+	wait(t, done, "run returning")
+}
+
+func TestLose(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	e := newTestElector(t, client, "replica-a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started, stopped, _ := term(ctx, e)
+	wait(t, started, "leading")
+
+	// another replica takes the lease over, for long
+	lease, err := client.CoordinationV1().Leases("monitoring").Get("telescope", meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := "replica-b"
+	duration := int32(60)
+	now := meta.NewMicroTime(time.Now())
+	lease.Spec = coordination.LeaseSpec{
+		HolderIdentity:       &other,
+		LeaseDurationSeconds: &duration,
+		AcquireTime:          &now,
+		RenewTime:            &now,
+	}
+	if _, err := client.CoordinationV1().Leases("monitoring").Update(lease); err != nil {
+		t.Fatal(err)
+	}
+
+	wait(t, stopped, "jobs cancelled on lost leadership")
+	deadline := time.Now().Add(testTimeout)
+	for e.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if e.IsLeader() {
+		t.Error("got leader after losing the lease")
+	}
+	if got := holder(t, client); got != other {
+		t.Errorf("got lease holder %q, want %s", got, other)
+	}
+}
+
+func TestHandover(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	a := newTestElector(t, client, "replica-a")
+	b := newTestElector(t, client, "replica-b")
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	startedA, stoppedA, doneA := term(ctxA, a)
+	wait(t, startedA, "replica-a leading")
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	startedB, _, _ := term(ctxB, b)
+
+	select {
+	case <-startedB:
+		t.Fatal("got 2 leaders")
+	case <-time.After(300 * time.Millisecond):
+	}
+	if b.IsLeader() {
+		t.Fatal("got replica-b leading with replica-a")
+	}
+
+	// replica-a shuts down, releasing the lease
+	cancelA()
+	wait(t, stoppedA, "replica-a jobs stopped")
+	wait(t, doneA, "replica-a run returning")
+	if a.IsLeader() {
+		t.Error("got replica-a leading after shutdown")
+	}
+
+	wait(t, startedB, "replica-b leading")
+	if !b.IsLeader() {
+		t.Error("got replica-b following after the handover")
+	}
+	if got := holder(t, client); got != "replica-b" {
+		t.Errorf("got lease holder %q, want replica-b", got)
+	}
+}
@@ -1,12 +1,21 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/danztran/telescope/pkg/collector"
 	"github.com/danztran/telescope/pkg/httpclient"
 	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshexport"
+	"github.com/danztran/telescope/pkg/netaudit"
+	"github.com/danztran/telescope/pkg/netpol"
+	"github.com/danztran/telescope/pkg/policy"
+	"github.com/danztran/telescope/pkg/storage"
 	"github.com/danztran/telescope/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -14,18 +23,41 @@ import (
 var defaultLogger = utils.MustGetLogger("handler")
 
 type Deps struct {
-	Log     *zap.SugaredLogger
-	Mapnode mapnode.Mapnode
+	Log       *zap.SugaredLogger
+	Collector collector.Collector
+	Mapnode   mapnode.Mapnode
+	Policy    policy.Policy
+	Netpol    netpol.Generator
+	Netaudit  netaudit.Auditor
+	Storage   storage.Storage
 }
 
 type Handler interface {
-	GetConnectionsByName(ctx context.Context, name string) (*GetNodeResponse, error)
+	GetConnectionsByName(ctx context.Context, name string, opt GetNodeOptions) (*GetNodeResponse, error)
 	GetAllConnections(ctx context.Context, opt GetAllNodesOptions) (*GetAllNodesResponse, error)
+	ExportConnections(ctx context.Context, opt GetAllNodesOptions) (*ExportResponse, error)
+	GetDownstream(ctx context.Context, name string, opt GetTraversalOptions) (*GetTraversalResponse, error)
+	GetUpstream(ctx context.Context, name string, opt GetTraversalOptions) (*GetTraversalResponse, error)
+	GetPath(ctx context.Context, name string, other string, opt GetPathOptions) (*GetPathResponse, error)
+	GetCycles(ctx context.Context) (*GetCyclesResponse, error)
+	GetUpdateJob(ctx context.Context, id string) (*mapnode.UpdateJob, error)
+	SubscribeEvents(ctx context.Context, opt GetEventsOptions) (<-chan mapnode.Diff, func())
+	GetPolicyViolations(ctx context.Context, opt GetViolationsOptions) (*GetViolationsResponse, error)
+	GetNetworkPolicies(ctx context.Context, opt GetNetworkPoliciesOptions) (*GetNetworkPoliciesResponse, error)
+	ExportNetworkPolicies(ctx context.Context, opt GetNetworkPoliciesOptions) (*ExportResponse, error)
+	GetNetworkPolicyAudit(ctx context.Context, opt GetNetworkPolicyAuditOptions) (*GetNetworkPolicyAuditResponse, error)
+	GetEdgeHistory(ctx context.Context, opt GetEdgeHistoryOptions) (*GetEdgeHistoryResponse, error)
+	ExplainEdges(ctx context.Context, opt ExplainEdgesOptions) (*ExplainEdgesResponse, error)
 }
 
 type handler struct {
-	log     *zap.SugaredLogger
-	mapnode mapnode.Mapnode
+	log       *zap.SugaredLogger
+	collector collector.Collector
+	mapnode   mapnode.Mapnode
+	policy    policy.Policy
+	netpol    netpol.Generator
+	netaudit  netaudit.Auditor
+	storage   storage.Storage
 }
 
 func MustNew(deps Deps) Handler {
@@ -42,43 +74,546 @@ func New(deps Deps) (Handler, error) {
 	}
 
 	h := &handler{
-		log:     deps.Log,
-		mapnode: deps.Mapnode,
+		log:       deps.Log,
+		collector: deps.Collector,
+		mapnode:   deps.Mapnode,
+		policy:    deps.Policy,
+		netpol:    deps.Netpol,
+		netaudit:  deps.Netaudit,
+		storage:   deps.Storage,
 	}
 	return h, nil
 }
 
-func (h *handler) GetConnectionsByName(ctx context.Context, name string) (*GetNodeResponse, error) {
-	node := h.mapnode.GetNode(name)
-	if node == nil {
+func (h *handler) GetConnectionsByName(ctx context.Context, name string, opt GetNodeOptions) (*GetNodeResponse, error) {
+	var nodes []mapnode.Node
+	if opt.Namespace != "" {
+		node := h.mapnode.GetNode(mapnode.NodeID(opt.Cluster, opt.Namespace, name))
+		if node != nil {
+			nodes = append(nodes, *node)
+		}
+	} else {
+		for _, node := range h.mapnode.GetNodesByName(name) {
+			if opt.Cluster != "" && node.Cluster != opt.Cluster {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+	}
+
+	if len(nodes) == 0 {
 		return nil, &httpclient.ErrNotFound{
 			Message: fmt.Sprintf("not found any node with name: %s", name),
 		}
 	}
 
 	resp := &GetNodeResponse{
-		Node:        *node,
+		Items:       nodes,
+		Total:       len(nodes),
 		LastUpdated: h.mapnode.SinceLastUpdated(),
 	}
+	if len(nodes) == 1 {
+		resp.Node = &nodes[0]
+	}
 
 	return resp, nil
 }
 
 func (h *handler) GetAllConnections(ctx context.Context, opt GetAllNodesOptions) (*GetAllNodesResponse, error) {
-	nodes := h.mapnode.GetAllNodes()
-	lastUpdated := h.mapnode.GetLastUpdated()
+	query, err := newNodeQuery(opt)
+	if err != nil {
+		return nil, err
+	}
 
-	if opt.ForceUpdate && time.Since(lastUpdated) > 60*time.Second {
-		err := h.mapnode.UpdateData(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("error update data / %w", err)
-		}
+	job, err := h.refresh(ctx, opt)
+	if err != nil {
+		return nil, err
 	}
 
+	matches := query.filter(h.mapnode.GetAllNodes())
 	resp := &GetAllNodesResponse{
-		Nodes:       nodes,
+		Total:       len(matches),
+		UpdateJob:   job,
 		LastUpdated: h.mapnode.SinceLastUpdated(),
 	}
 
+	if !query.paginated {
+		nodes := make(map[string]mapnode.Node, len(matches))
+		for _, n := range matches {
+			nodes[n.node.ID] = n.node
+		}
+		resp.NodesByID = &NodesByID{Nodes: nodes}
+		resp.Count = len(nodes)
+		return resp, nil
+	}
+
+	page, nextCursor := query.page(matches, opt.Sort)
+	views := make([]interface{}, len(page))
+	for i, n := range page {
+		views[i] = query.view(n)
+	}
+	resp.NodesPage = &NodesPage{Items: views, NextCursor: nextCursor}
+	resp.Count = len(views)
+
+	return resp, nil
+}
+
+// refresh request a data update if forced, and wait for it unless async.
+// It returns the requested update, nil if the data is fresh enough.
+func (h *handler) refresh(ctx context.Context, opt GetAllNodesOptions) (*mapnode.UpdateJob, error) {
+	if !opt.ForceUpdate {
+		return nil, nil
+	}
+
+	job := h.mapnode.RequestUpdate()
+	if job == nil || opt.Async {
+		return job, nil
+	}
+
+	job, err := h.mapnode.WaitUpdateJob(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error wait update data / %w", err)
+	}
+	if job.Status == mapnode.UpdateFailed {
+		return nil, fmt.Errorf("error update data / %s", job.Error)
+	}
+
+	return job, nil
+}
+
+// ExportConnections render all connections in a graph format
+func (h *handler) ExportConnections(ctx context.Context, opt GetAllNodesOptions) (*ExportResponse, error) {
+	format, err := meshexport.ParseFormat(opt.Format)
+	if err != nil {
+		return nil, &httpclient.ErrClient{Message: err.Error()}
+	}
+
+	query, err := newNodeQuery(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	// exports always wait for the update
+	opt.Async = false
+	if _, err := h.refresh(ctx, opt); err != nil {
+		return nil, err
+	}
+
+	// filters apply to exports, pagination does not
+	matches := make(map[string]mapnode.Node)
+	for _, n := range query.filter(h.mapnode.GetAllNodes()) {
+		matches[n.node.ID] = n.node
+	}
+
+	buf := new(bytes.Buffer)
+	if err := meshexport.Render(buf, format, matches); err != nil {
+		return nil, fmt.Errorf("error render %s / %w", format, err)
+	}
+
+	resp := &ExportResponse{
+		ContentType: format.ContentType(),
+		Data:        buf.Bytes(),
+	}
+
+	return resp, nil
+}
+
+func (h *handler) GetDownstream(ctx context.Context, name string, opt GetTraversalOptions) (*GetTraversalResponse, error) {
+	node, err := h.resolveNode(name, opt.Namespace, opt.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	reaches := h.mapnode.Downstream(node.ID, opt.Depth)
+	resp := &GetTraversalResponse{
+		Node:        node.ID,
+		Nodes:       reaches,
+		Total:       len(reaches),
+		LastUpdated: h.mapnode.SinceLastUpdated(),
+	}
+
+	return resp, nil
+}
+
+func (h *handler) GetUpstream(ctx context.Context, name string, opt GetTraversalOptions) (*GetTraversalResponse, error) {
+	node, err := h.resolveNode(name, opt.Namespace, opt.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	reaches := h.mapnode.Upstream(node.ID, opt.Depth)
+	resp := &GetTraversalResponse{
+		Node:        node.ID,
+		Nodes:       reaches,
+		Total:       len(reaches),
+		LastUpdated: h.mapnode.SinceLastUpdated(),
+	}
+
+	return resp, nil
+}
+
+func (h *handler) GetPath(ctx context.Context, name string, other string, opt GetPathOptions) (*GetPathResponse, error) {
+	from, err := h.resolveNode(name, opt.Namespace, opt.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := h.resolveNode(other, opt.OtherNamespace, opt.OtherCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	path := h.mapnode.ShortestPath(from.ID, to.ID)
+	if path == nil {
+		return nil, &httpclient.ErrNotFound{
+			Message: fmt.Sprintf("not found any path from %s to %s", from.ID, to.ID),
+		}
+	}
+
+	resp := &GetPathResponse{
+		Path:        path,
+		Hops:        len(path) - 1,
+		LastUpdated: h.mapnode.SinceLastUpdated(),
+	}
+
+	return resp, nil
+}
+
+func (h *handler) GetCycles(ctx context.Context) (*GetCyclesResponse, error) {
+	cycles := h.mapnode.Cycles()
+	resp := &GetCyclesResponse{
+		Cycles:      cycles,
+		Total:       len(cycles),
+		LastUpdated: h.mapnode.SinceLastUpdated(),
+	}
+
+	return resp, nil
+}
+
+func (h *handler) GetUpdateJob(ctx context.Context, id string) (*mapnode.UpdateJob, error) {
+	job := h.mapnode.GetUpdateJob(id)
+	if job == nil {
+		return nil, &httpclient.ErrNotFound{
+			Message: fmt.Sprintf("not found any update job with id: %s", id),
+		}
+	}
+
+	return job, nil
+}
+
+// GetPolicyViolations get violations of the last policy evaluation,
+// filtered by source or destination namespace and by origin
+func (h *handler) GetPolicyViolations(ctx context.Context, opt GetViolationsOptions) (*GetViolationsResponse, error) {
+	if h.policy == nil || !h.policy.Enabled() {
+		return nil, &httpclient.ErrNotFound{
+			Message: "policy is not configured",
+		}
+	}
+
+	report := h.policy.GetReport()
+	violations := []policy.Violation{}
+	for _, v := range report.Violations {
+		if opt.Namespace != "" && v.SourceNamespace != opt.Namespace && v.DestinationNamespace != opt.Namespace {
+			continue
+		}
+		if opt.Origin != "" && !contains(v.Origins, opt.Origin) {
+			continue
+		}
+		violations = append(violations, v)
+	}
+
+	resp := &GetViolationsResponse{
+		Violations:  violations,
+		Total:       len(violations),
+		Edges:       report.Edges,
+		EvaluatedAt: utils.SinceTime(report.EvaluatedAt, time.Second),
+	}
+
+	return resp, nil
+}
+
+// GetNetworkPolicies generate network policies from observed connections
+func (h *handler) GetNetworkPolicies(ctx context.Context, opt GetNetworkPoliciesOptions) (*GetNetworkPoliciesResponse, error) {
+	result, err := h.generateNetworkPolicies(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &GetNetworkPoliciesResponse{
+		Policies:   result.Policies,
+		Total:      len(result.Policies),
+		Unresolved: result.Unresolved,
+		Incomplete: result.Incomplete,
+		Diffs:      result.Diffs,
+	}
+
+	return resp, nil
+}
+
+// ExportNetworkPolicies generate network policies as a YAML manifest
+func (h *handler) ExportNetworkPolicies(ctx context.Context, opt GetNetworkPoliciesOptions) (*ExportResponse, error) {
+	opt.DryRun = false
+	result, err := h.generateNetworkPolicies(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := netpol.Render(buf, result.Policies); err != nil {
+		return nil, fmt.Errorf("error render network policies / %w", err)
+	}
+
+	resp := &ExportResponse{
+		ContentType: netpol.ContentType,
+		Data:        buf.Bytes(),
+	}
+
+	return resp, nil
+}
+
+func (h *handler) generateNetworkPolicies(ctx context.Context, opt GetNetworkPoliciesOptions) (*netpol.Result, error) {
+	if h.netpol == nil {
+		return nil, &httpclient.ErrNotFound{
+			Message: "network policy generator is not configured",
+		}
+	}
+
+	genOpt := netpol.Options{
+		Namespace: opt.Namespace,
+		DryRun:    opt.DryRun,
+	}
+	if opt.Window != "" {
+		window, err := time.ParseDuration(opt.Window)
+		if err != nil || window <= 0 {
+			return nil, &httpclient.ErrClient{
+				Message: fmt.Sprintf("invalid window: %s", opt.Window),
+			}
+		}
+		genOpt.Window = &window
+	}
+
+	result, err := h.netpol.Generate(ctx, genOpt)
+	if err != nil {
+		return nil, fmt.Errorf("error generate network policies / %w", err)
+	}
+
+	return result, nil
+}
+
+// GetNetworkPolicyAudit get decisions of network policies on observed edges
+// of the last audit, filtered by source or destination namespace and verdict,
+// and unused rules of policies in the namespace
+func (h *handler) GetNetworkPolicyAudit(ctx context.Context, opt GetNetworkPolicyAuditOptions) (*GetNetworkPolicyAuditResponse, error) {
+	if h.netaudit == nil {
+		return nil, &httpclient.ErrNotFound{
+			Message: "network policy audit is not configured",
+		}
+	}
+
+	var match func(netaudit.Verdict) bool
+	switch opt.Verdict {
+	case "":
+		match = func(netaudit.Verdict) bool { return true }
+	case VerdictAllowed:
+		match = func(v netaudit.Verdict) bool { return v.Allowed }
+	case VerdictDenied:
+		match = func(v netaudit.Verdict) bool {
+			return v.Ingress == netaudit.DecisionDenied || v.Egress == netaudit.DecisionDenied
+		}
+	case VerdictDefaultDenyBlocked:
+		match = func(v netaudit.Verdict) bool { return len(v.DefaultDenyBlocked) > 0 }
+	default:
+		return nil, &httpclient.ErrClient{
+			Message: fmt.Sprintf("invalid verdict: %s", opt.Verdict),
+		}
+	}
+
+	report := h.netaudit.GetReport()
+	edges := []netaudit.Verdict{}
+	for _, v := range report.Edges {
+		if opt.Namespace != "" && v.SourceNamespace != opt.Namespace && v.DestinationNamespace != opt.Namespace {
+			continue
+		}
+		if match(v) {
+			edges = append(edges, v)
+		}
+	}
+	rules := []netaudit.Rule{}
+	for _, r := range report.UnusedRules {
+		if opt.Namespace == "" || r.Namespace == opt.Namespace {
+			rules = append(rules, r)
+		}
+	}
+
+	resp := &GetNetworkPolicyAuditResponse{
+		Edges:       edges,
+		Total:       len(edges),
+		UnusedRules: rules,
+		EvaluatedAt: utils.SinceTime(report.EvaluatedAt, time.Second),
+	}
+
+	return resp, nil
+}
+
+// GetEdgeHistory get persisted edges seen over a window,
+// from or to a namespace if set
+func (h *handler) GetEdgeHistory(ctx context.Context, opt GetEdgeHistoryOptions) (*GetEdgeHistoryResponse, error) {
+	if h.storage == nil {
+		return nil, &httpclient.ErrNotFound{
+			Message: "storage is not enabled",
+		}
+	}
+
+	since := defaultHistorySince
+	if opt.Since != "" {
+		var err error
+		since, err = time.ParseDuration(opt.Since)
+		if err != nil || since <= 0 {
+			return nil, &httpclient.ErrClient{
+				Message: fmt.Sprintf("invalid since: %s", opt.Since),
+			}
+		}
+	}
+
+	end := time.Now()
+	edges, err := h.storage.GetEdges(ctx, end.Add(-since), end)
+	if err != nil {
+		return nil, fmt.Errorf("error get edge history / %w", err)
+	}
+
+	filtered := []storage.Edge{}
+	for _, e := range edges {
+		if opt.Namespace != "" && e.SourceNamespace != opt.Namespace && e.DestinationNamespace != opt.Namespace {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	resp := &GetEdgeHistoryResponse{
+		Edges: filtered,
+		Total: len(filtered),
+	}
+
+	return resp, nil
+}
+
+// SubscribeEvents get diffs of next mesh updates, until unsubscribed.
+// Diffs are filtered by namespace if set, and skipped if nothing is left.
+func (h *handler) SubscribeEvents(ctx context.Context, opt GetEventsOptions) (<-chan mapnode.Diff, func()) {
+	diffs, unsubscribe := h.mapnode.Subscribe()
+	if opt.Namespace == "" {
+		return diffs, unsubscribe
+	}
+
+	// forwarding blocks a slow subscriber, its next diffs being merged by mapnode
+	filtered := make(chan mapnode.Diff, cap(diffs))
+	done := make(chan struct{})
+	go func() {
+		defer close(filtered)
+		for diff := range diffs {
+			diff = filterDiff(diff, opt.Namespace)
+			if diff.Empty() {
+				continue
+			}
+			select {
+			case filtered <- diff:
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(done) })
+		unsubscribe()
+	}
+
+	return filtered, stop
+}
+
+// filterDiff keep changes of nodes in a namespace,
+// and edges from or to them
+func filterDiff(diff mapnode.Diff, namespace string) mapnode.Diff {
+	nodes := diff.Nodes
+	edges := diff.Edges
+	diff.Nodes = []mapnode.NodeEvent{}
+	diff.Edges = []mapnode.EdgeEvent{}
+
+	inNamespace := func(id string) bool {
+		_, ns, _ := mapnode.SplitNodeID(id)
+		return ns == namespace
+	}
+
+	for _, event := range nodes {
+		if event.Node.Namespace == namespace {
+			diff.Nodes = append(diff.Nodes, event)
+		}
+	}
+	for _, edge := range edges {
+		if inNamespace(edge.Source) || inNamespace(edge.Destination) {
+			diff.Edges = append(diff.Edges, edge)
+		}
+	}
+
+	return diff
+}
+
+// resolveNode find the single node matching a short name,
+// namespace and cluster are required only if the name is ambiguous
+func (h *handler) resolveNode(name string, namespace string, cluster string) (*mapnode.Node, error) {
+	if namespace != "" {
+		node := h.mapnode.GetNode(mapnode.NodeID(cluster, namespace, name))
+		if node == nil {
+			return nil, &httpclient.ErrNotFound{
+				Message: fmt.Sprintf("not found any node with name: %s", name),
+			}
+		}
+		return node, nil
+	}
+
+	nodes := []mapnode.Node{}
+	for _, node := range h.mapnode.GetNodesByName(name) {
+		if cluster != "" && node.Cluster != cluster {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil, &httpclient.ErrNotFound{
+			Message: fmt.Sprintf("not found any node with name: %s", name),
+		}
+	case 1:
+		return &nodes[0], nil
+	}
+
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return nil, &httpclient.ErrClient{
+		Message: fmt.Sprintf("ambiguous node name %s, specify a namespace: %s", name, strings.Join(ids, ", ")),
+	}
+}
+
+// ExplainEdges get the decision trail of candidate connections
+// from src to dest in the last collection cycle
+func (h *handler) ExplainEdges(ctx context.Context, opt ExplainEdgesOptions) (*ExplainEdgesResponse, error) {
+	if h.collector == nil {
+		return nil, &httpclient.ErrNotFound{
+			Message: "collector is not running",
+		}
+	}
+
+	decisions := h.collector.Explain(opt.Source, opt.Destination)
+	resp := &ExplainEdgesResponse{
+		Decisions: decisions,
+		Total:     len(decisions),
+	}
+
 	return resp, nil
 }
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+type connections []mapnode.Connection
+
+func (c connections) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
+	return c, nil
+}
+
+func newTestHandler(t *testing.T) Handler {
+	t.Helper()
+	m, err := mapnode.New(mapnode.Deps{MetricsClient: connections{
+		{SourceNamespace: "payments", Source: "api", DestinationNamespace: "payments", Destination: "db", DestinationPort: "5432"},
+		{SourceNamespace: "billing", Source: "api", DestinationNamespace: "payments", Destination: "db", DestinationPort: "5432"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateData(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return MustNew(Deps{Mapnode: m})
+}
+
+// fields get the top level fields of a response encoded in JSON
+func fields(t *testing.T, resp interface{}) map[string]json.RawMessage {
+	t.Helper()
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestGetAllConnectionsEnvelopes(t *testing.T) {
+	h := newTestHandler(t)
+
+	tests := []struct {
+		name    string
+		opt     GetAllNodesOptions
+		present []string
+		absent  []string
+		items   int
+	}{
+		{name: "legacy", present: []string{"nodes"}, absent: []string{"items", "next_cursor"}},
+		{name: "legacy empty", opt: GetAllNodesOptions{Namespace: "none"}, present: []string{"nodes"}, absent: []string{"items"}},
+		{name: "paginated", opt: GetAllNodesOptions{Limit: 1}, present: []string{"items", "next_cursor"}, absent: []string{"nodes"}, items: 1},
+		{name: "sorted", opt: GetAllNodesOptions{Sort: "-degree"}, present: []string{"items", "next_cursor"}, absent: []string{"nodes"}, items: 3},
+		{name: "fields", opt: GetAllNodesOptions{Fields: "id"}, present: []string{"items", "next_cursor"}, absent: []string{"nodes"}, items: 3},
+		{name: "empty page", opt: GetAllNodesOptions{Namespace: "none", Limit: 1}, present: []string{"items", "next_cursor"}, absent: []string{"nodes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := h.GetAllConnections(context.Background(), tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := fields(t, resp)
+			for _, field := range tt.present {
+				if _, ok := got[field]; !ok {
+					t.Errorf("got no %s field", field)
+				}
+			}
+			for _, field := range tt.absent {
+				if _, ok := got[field]; ok {
+					t.Errorf("got %s field", field)
+				}
+			}
+
+			if raw, ok := got["items"]; ok {
+				var items []json.RawMessage
+				if err := json.Unmarshal(raw, &items); err != nil {
+					t.Fatalf("got items %s, want a list / %s", raw, err)
+				}
+				if len(items) != tt.items {
+					t.Errorf("got %d items, want %d", len(items), tt.items)
+				}
+			}
+		})
+	}
+}
+
+func TestGetConnectionsByName(t *testing.T) {
+	h := newTestHandler(t)
+
+	resp, err := h.GetConnectionsByName(context.Background(), "api", GetNodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Node != nil || len(resp.Items) != 2 {
+		t.Errorf("got node %v & %d items of an ambiguous name, want 2 items only", resp.Node, len(resp.Items))
+	}
+
+	resp, err = h.GetConnectionsByName(context.Background(), "api", GetNodeOptions{Namespace: "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Node == nil || len(resp.Items) != 1 || resp.Items[0].ID != resp.Node.ID {
+		t.Errorf("got node %v & items %v, want the node in both", resp.Node, resp.Items)
+	}
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/danztran/telescope/pkg/httpclient"
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+const (
+	fieldID        = "id"
+	fieldName      = "name"
+	fieldNamespace = "namespace"
+	fieldCluster   = "cluster"
+	fieldOutbounds = "outbounds"
+	fieldInbounds  = "inbounds"
+	fieldInDegree  = "in_degree"
+	fieldOutDegree = "out_degree"
+	fieldDegree    = "degree"
+)
+
+var (
+	selectableFields = []string{
+		fieldID, fieldName, fieldNamespace, fieldCluster,
+		fieldOutbounds, fieldInbounds, fieldInDegree, fieldOutDegree, fieldDegree,
+	}
+	sortableFields = []string{
+		fieldID, fieldName, fieldNamespace, fieldInDegree, fieldOutDegree, fieldDegree,
+	}
+)
+
+// nodeQuery is a validated GetAllNodesOptions
+type nodeQuery struct {
+	namespaces  map[string]bool
+	nameGlob    string
+	nameRegex   *regexp.Regexp
+	port        string
+	minDegree   int
+	hasInbound  *bool
+	hasOutbound *bool
+	sortField   string
+	sortDesc    bool
+	limit       int
+	cursor      *cursor
+	fields      []string
+	// paginated is set by pagination, sorting or field selection,
+	// listing nodes instead of keying them by id
+	paginated bool
+}
+
+// cursor is the position of the last returned node, in sort order
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"i"`
+}
+
+// queryNode is a node with its computed attributes
+type queryNode struct {
+	node  mapnode.Node
+	ports map[string]bool
+}
+
+func (n queryNode) inDegree() int  { return len(n.node.Inbounds) }
+func (n queryNode) outDegree() int { return len(n.node.Outbounds) }
+func (n queryNode) degree() int    { return n.inDegree() + n.outDegree() }
+
+// sortValue get the node value of a sortable field,
+// numbers are padded to be compared as strings
+func (n queryNode) sortValue(field string) string {
+	switch field {
+	case fieldName:
+		return n.node.Name
+	case fieldNamespace:
+		return n.node.Namespace
+	case fieldInDegree:
+		return fmt.Sprintf("%010d", n.inDegree())
+	case fieldOutDegree:
+		return fmt.Sprintf("%010d", n.outDegree())
+	case fieldDegree:
+		return fmt.Sprintf("%010d", n.degree())
+	}
+	return n.node.ID
+}
+
+func newNodeQuery(opt GetAllNodesOptions) (*nodeQuery, error) {
+	q := &nodeQuery{
+		nameGlob:    opt.Name,
+		port:        opt.Port,
+		minDegree:   opt.MinDegree,
+		hasInbound:  opt.HasInbound,
+		hasOutbound: opt.HasOutbound,
+		limit:       opt.Limit,
+		sortField:   fieldID,
+		paginated:   opt.Limit > 0 || opt.Cursor != "" || opt.Sort != "" || opt.Fields != "",
+	}
+
+	if opt.Namespace != "" {
+		q.namespaces = map[string]bool{}
+		for _, ns := range strings.Split(opt.Namespace, ",") {
+			q.namespaces[strings.TrimSpace(ns)] = true
+		}
+	}
+
+	if opt.Name != "" {
+		if _, err := path.Match(opt.Name, ""); err != nil {
+			return nil, &httpclient.ErrClient{Message: fmt.Sprintf("invalid name glob: %s / %s", opt.Name, err)}
+		}
+	}
+
+	if opt.NameRegex != "" {
+		re, err := regexp.Compile(opt.NameRegex)
+		if err != nil {
+			return nil, &httpclient.ErrClient{Message: fmt.Sprintf("invalid name regex: %s / %s", opt.NameRegex, err)}
+		}
+		q.nameRegex = re
+	}
+
+	if opt.Limit < 0 {
+		return nil, &httpclient.ErrClient{Message: fmt.Sprintf("invalid limit: %d", opt.Limit)}
+	}
+
+	if opt.Sort != "" {
+		q.sortDesc = strings.HasPrefix(opt.Sort, "-")
+		q.sortField = strings.TrimPrefix(opt.Sort, "-")
+		if !contains(sortableFields, q.sortField) {
+			return nil, &httpclient.ErrClient{Message: fmt.Sprintf("invalid sort field: %s (sortable: %v)", opt.Sort, sortableFields)}
+		}
+	}
+
+	if opt.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(opt.Cursor)
+		q.cursor = new(cursor)
+		if err == nil {
+			err = json.Unmarshal(data, q.cursor)
+		}
+		if err != nil || q.cursor.Sort != opt.Sort {
+			return nil, &httpclient.ErrClient{Message: fmt.Sprintf("invalid cursor: %s", opt.Cursor)}
+		}
+	}
+
+	if opt.Fields != "" {
+		for _, field := range strings.Split(opt.Fields, ",") {
+			field = strings.TrimSpace(field)
+			if !contains(selectableFields, field) {
+				return nil, &httpclient.ErrClient{Message: fmt.Sprintf("invalid field: %s (selectable: %v)", field, selectableFields)}
+			}
+			q.fields = append(q.fields, field)
+		}
+	}
+
+	return q, nil
+}
+
+// filter get nodes matching the query filters
+func (q *nodeQuery) filter(nodes map[string]mapnode.Node) []queryNode {
+	ports := map[string]map[string]bool{}
+	addPort := func(id string, port string) {
+		if ports[id] == nil {
+			ports[id] = map[string]bool{}
+		}
+		ports[id][port] = true
+	}
+	if q.port != "" {
+		for id, node := range nodes {
+			for _, out := range node.Outbounds {
+				addPort(id, out.Port)
+				addPort(out.ID, out.Port)
+			}
+		}
+	}
+
+	matches := []queryNode{}
+	for id, node := range nodes {
+		n := queryNode{node: node, ports: ports[id]}
+		if q.match(n) {
+			matches = append(matches, n)
+		}
+	}
+
+	return matches
+}
+
+func (q *nodeQuery) match(n queryNode) bool {
+	node := n.node
+	if q.namespaces != nil && !q.namespaces[node.Namespace] {
+		return false
+	}
+	if q.nameGlob != "" {
+		if matched, _ := path.Match(q.nameGlob, node.Name); !matched {
+			return false
+		}
+	}
+	if q.nameRegex != nil && !q.nameRegex.MatchString(node.Name) {
+		return false
+	}
+	if q.port != "" && !n.ports[q.port] {
+		return false
+	}
+	if n.degree() < q.minDegree {
+		return false
+	}
+	if q.hasInbound != nil && (n.inDegree() > 0) != *q.hasInbound {
+		return false
+	}
+	if q.hasOutbound != nil && (n.outDegree() > 0) != *q.hasOutbound {
+		return false
+	}
+	return true
+}
+
+// less compare 2 nodes positions (value, id) in sort order
+func (q *nodeQuery) less(value1, id1, value2, id2 string) bool {
+	if value1 != value2 {
+		return (value1 < value2) != q.sortDesc
+	}
+	return id1 < id2
+}
+
+// page sort nodes, skip nodes up to the cursor and take a page of them.
+// It returns the page and the cursor of the next page, empty if there is none.
+func (q *nodeQuery) page(nodes []queryNode, sortParam string) ([]queryNode, string) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return q.less(
+			nodes[i].sortValue(q.sortField), nodes[i].node.ID,
+			nodes[j].sortValue(q.sortField), nodes[j].node.ID,
+		)
+	})
+
+	start := 0
+	if q.cursor != nil {
+		start = sort.Search(len(nodes), func(i int) bool {
+			return q.less(q.cursor.Value, q.cursor.ID, nodes[i].sortValue(q.sortField), nodes[i].node.ID)
+		})
+	}
+	nodes = nodes[start:]
+
+	if q.limit == 0 || len(nodes) <= q.limit {
+		return nodes, ""
+	}
+
+	nodes = nodes[:q.limit]
+	last := nodes[len(nodes)-1]
+	data, _ := json.Marshal(cursor{
+		Sort:  sortParam,
+		Value: last.sortValue(q.sortField),
+		ID:    last.node.ID,
+	})
+
+	return nodes, base64.RawURLEncoding.EncodeToString(data)
+}
+
+// view get the node representation with only the selected fields,
+// the full node if no fields are selected
+func (q *nodeQuery) view(n queryNode) interface{} {
+	if len(q.fields) == 0 {
+		return n.node
+	}
+
+	data := make(map[string]interface{}, len(q.fields))
+	for _, field := range q.fields {
+		switch field {
+		case fieldID:
+			data[field] = n.node.ID
+		case fieldName:
+			data[field] = n.node.Name
+		case fieldNamespace:
+			data[field] = n.node.Namespace
+		case fieldCluster:
+			data[field] = n.node.Cluster
+		case fieldOutbounds:
+			data[field] = n.node.Outbounds
+		case fieldInbounds:
+			data[field] = n.node.Inbounds
+		case fieldInDegree:
+			data[field] = n.inDegree()
+		case fieldOutDegree:
+			data[field] = n.outDegree()
+		case fieldDegree:
+			data[field] = n.degree()
+		}
+	}
+
+	return data
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
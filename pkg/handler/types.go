@@ -1,17 +1,185 @@
 package handler
 
-import "github.com/danztran/telescope/pkg/mapnode"
+import (
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/netaudit"
+	"github.com/danztran/telescope/pkg/netpol"
+	"github.com/danztran/telescope/pkg/policy"
+	"github.com/danztran/telescope/pkg/storage"
+	networking "k8s.io/api/networking/v1"
+)
 
 type GetNodeResponse struct {
-	Node        mapnode.Node `json:"node"`
-	LastUpdated string       `json:"last_updated"`
+	// Node is the node having the name, if it is the only one
+	Node *mapnode.Node `json:"node,omitempty"`
+	// Items are all the nodes having the name, across namespaces or clusters
+	Items       []mapnode.Node `json:"items"`
+	Total       int            `json:"total"`
+	LastUpdated string         `json:"last_updated"`
 }
 
+// GetAllNodesResponse is either NodesByID, without pagination, sorting nor
+// selected fields, or a NodesPage
 type GetAllNodesResponse struct {
-	Nodes       map[string]mapnode.Node `json:"nodes"`
-	LastUpdated string                  `json:"last_updated"`
+	*NodesByID
+	*NodesPage
+	Total int `json:"total"`
+	Count int `json:"count"`
+	// UpdateJob is the update requested by force_update, if any
+	UpdateJob   *mapnode.UpdateJob `json:"update_job,omitempty"`
+	LastUpdated string             `json:"last_updated"`
+}
+
+type NodesByID struct {
+	Nodes map[string]mapnode.Node `json:"nodes"`
+}
+
+type NodesPage struct {
+	// Items are mapnode.Node, or maps of their selected fields, in sort order
+	Items []interface{} `json:"items"`
+	// NextCursor is the cursor of the next page, empty on the last one
+	NextCursor string `json:"next_cursor"`
 }
 
 type GetAllNodesOptions struct {
-	ForceUpdate bool `json:"force_update" form:"force_update" query:"force_update"`
+	ForceUpdate bool   `json:"force_update" form:"force_update" query:"force_update"`
+	Async       bool   `json:"async" form:"async" query:"async"`
+	Format      string `json:"format" form:"format" query:"format"`
+
+	// filters
+	Namespace   string `json:"namespace" form:"namespace" query:"namespace"`
+	Name        string `json:"name" form:"name" query:"name"`
+	NameRegex   string `json:"name_regex" form:"name_regex" query:"name_regex"`
+	Port        string `json:"port" form:"port" query:"port"`
+	MinDegree   int    `json:"min_degree" form:"min_degree" query:"min_degree"`
+	HasInbound  *bool  `json:"has_inbound" form:"has_inbound" query:"has_inbound"`
+	HasOutbound *bool  `json:"has_outbound" form:"has_outbound" query:"has_outbound"`
+
+	// pagination, sorting & sparse fieldsets
+	Cursor string `json:"cursor" form:"cursor" query:"cursor"`
+	Limit  int    `json:"limit" form:"limit" query:"limit"`
+	Sort   string `json:"sort" form:"sort" query:"sort"`
+	Fields string `json:"fields" form:"fields" query:"fields"`
+}
+
+type ExportResponse struct {
+	ContentType string
+	Data        []byte
+}
+
+type GetNodeOptions struct {
+	Namespace string `json:"namespace" form:"namespace" query:"namespace"`
+	Cluster   string `json:"cluster" form:"cluster" query:"cluster"`
+}
+
+type GetTraversalOptions struct {
+	Namespace string `json:"namespace" form:"namespace" query:"namespace"`
+	Cluster   string `json:"cluster" form:"cluster" query:"cluster"`
+	Depth     int    `json:"depth" form:"depth" query:"depth"`
+}
+
+type GetTraversalResponse struct {
+	Node        string          `json:"node"`
+	Nodes       []mapnode.Reach `json:"nodes"`
+	Total       int             `json:"total"`
+	LastUpdated string          `json:"last_updated"`
+}
+
+type GetPathOptions struct {
+	Namespace      string `json:"namespace" form:"namespace" query:"namespace"`
+	Cluster        string `json:"cluster" form:"cluster" query:"cluster"`
+	OtherNamespace string `json:"other_namespace" form:"other_namespace" query:"other_namespace"`
+	OtherCluster   string `json:"other_cluster" form:"other_cluster" query:"other_cluster"`
+}
+
+type GetPathResponse struct {
+	Path        []string `json:"path"`
+	Hops        int      `json:"hops"`
+	LastUpdated string   `json:"last_updated"`
+}
+
+type GetCyclesResponse struct {
+	Cycles      [][]string `json:"cycles"`
+	Total       int        `json:"total"`
+	LastUpdated string     `json:"last_updated"`
+}
+
+type GetEventsOptions struct {
+	Namespace string `json:"namespace" form:"namespace" query:"namespace"`
+}
+
+type GetViolationsOptions struct {
+	Namespace string `json:"namespace" form:"namespace" query:"namespace"`
+	Origin    string `json:"origin" form:"origin" query:"origin"`
+}
+
+type GetViolationsResponse struct {
+	Violations []policy.Violation `json:"violations"`
+	Total      int                `json:"total"`
+	// Edges is the number of evaluated edges
+	Edges       int    `json:"edges"`
+	EvaluatedAt string `json:"evaluated_at"`
+}
+
+type GetNetworkPoliciesOptions struct {
+	Namespace string `json:"namespace" form:"namespace" query:"namespace"`
+	Window    string `json:"window" form:"window" query:"window"`
+	DryRun    bool   `json:"dry_run" form:"dry_run" query:"dry_run"`
+	Format    string `json:"format" form:"format" query:"format"`
+}
+
+type GetNetworkPoliciesResponse struct {
+	Policies []networking.NetworkPolicy `json:"policies"`
+	Total    int                        `json:"total"`
+	// Unresolved are graph nodes without known workload in the cluster
+	Unresolved []string `json:"unresolved"`
+	// Incomplete are graph nodes with unresolved or other cluster peers,
+	// getting no policy
+	Incomplete []string      `json:"incomplete"`
+	Diffs      []netpol.Diff `json:"diffs,omitempty"`
+}
+
+// verdicts filtering network policy audits
+const (
+	VerdictAllowed            string = "allowed"
+	VerdictDenied             string = "denied"
+	VerdictDefaultDenyBlocked string = "default_deny_blocked"
+)
+
+type GetNetworkPolicyAuditOptions struct {
+	Namespace string `json:"namespace" form:"namespace" query:"namespace"`
+	Verdict   string `json:"verdict" form:"verdict" query:"verdict"`
+}
+
+type GetNetworkPolicyAuditResponse struct {
+	Edges       []netaudit.Verdict `json:"edges"`
+	Total       int                `json:"total"`
+	UnusedRules []netaudit.Rule    `json:"unused_rules"`
+	EvaluatedAt string             `json:"evaluated_at"`
+}
+
+// defaultHistorySince is the default window of edge history
+const defaultHistorySince = 30 * 24 * time.Hour
+
+type GetEdgeHistoryOptions struct {
+	Since     string `json:"since" form:"since" query:"since"`
+	Namespace string `json:"namespace" form:"namespace" query:"namespace"`
+}
+
+type GetEdgeHistoryResponse struct {
+	Edges []storage.Edge `json:"edges"`
+	Total int            `json:"total"`
+}
+
+type ExplainEdgesOptions struct {
+	Source      string `json:"src" form:"src" query:"src"`
+	Destination string `json:"dest" form:"dest" query:"dest"`
+}
+
+type ExplainEdgesResponse struct {
+	Decisions []collector.Decision `json:"decisions"`
+	Total     int                  `json:"total"`
 }
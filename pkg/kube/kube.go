@@ -2,12 +2,14 @@ package kube
 
 import (
 	"fmt"
+	"regexp"
 	"sync"
 
 	"github.com/danztran/telescope/pkg/kube/store"
 	"github.com/danztran/telescope/pkg/utils"
 	"go.uber.org/zap"
 	core "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -17,6 +19,9 @@ var (
 	DefaultConfig = Config{
 		Kubeconfig: DefaultConfigPath,
 	}
+
+	// regexpOrdinal match ordinal suffixes of pod names, e.g. statefulset pods
+	regexpOrdinal = regexp.MustCompile(`-\d+$`)
 )
 
 type Deps struct {
@@ -31,6 +36,9 @@ type Config struct {
 type Kube interface {
 	GetRootObject(uid string) meta.Object
 	GetPod(uid string) (*core.Pod, error)
+	GetWorkload(namespace string, name string) meta.Object
+	GetNetworkPolicies(namespace string) []networking.NetworkPolicy
+	GetNamespace(name string) *core.Namespace
 }
 
 type kube struct {
@@ -111,3 +119,68 @@ func (k *kube) GetPod(uid string) (*core.Pod, error) {
 
 	return &clonePod, nil
 }
+
+// GetWorkload get a root object by namespace & name, as named by the collector.
+// Pods without known owner match their name without ordinal suffix,
+// other workloads are preferred over them. Objects of other kinds sharing
+// the store, e.g. network policies, are never matched.
+func (k *kube) GetWorkload(namespace string, name string) meta.Object {
+	var workload meta.Object
+	k.store.Range(func(object meta.Object) bool {
+		if object.GetNamespace() != namespace || !IsWorkload(object) || !k.isRoot(object) {
+			return true
+		}
+		if _, ok := object.(*core.Pod); ok {
+			if workload == nil && regexpOrdinal.ReplaceAllString(object.GetName(), "") == name {
+				workload = object
+			}
+			return true
+		}
+		if object.GetName() == name {
+			workload = object
+			return false
+		}
+		return true
+	})
+
+	return workload
+}
+
+// isRoot check whether an object has no owner in the store
+func (k *kube) isRoot(object meta.Object) bool {
+	for _, ref := range object.GetOwnerReferences() {
+		if k.store.Get(ref.UID) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// GetNetworkPolicies get network policies of a namespace, all namespaces if empty
+func (k *kube) GetNetworkPolicies(namespace string) []networking.NetworkPolicy {
+	policies := []networking.NetworkPolicy{}
+	k.store.Range(func(object meta.Object) bool {
+		policy, ok := object.(*networking.NetworkPolicy)
+		if ok && (namespace == "" || policy.Namespace == namespace) {
+			policies = append(policies, *policy.DeepCopy())
+		}
+		return true
+	})
+
+	return policies
+}
+
+// GetNamespace get a namespace by name
+func (k *kube) GetNamespace(name string) *core.Namespace {
+	var namespace *core.Namespace
+	k.store.Range(func(object meta.Object) bool {
+		ns, ok := object.(*core.Namespace)
+		if ok && ns.Name == name {
+			namespace = ns.DeepCopy()
+			return false
+		}
+		return true
+	})
+
+	return namespace
+}
@@ -0,0 +1,58 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/danztran/telescope/pkg/kube/store"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	core "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func objectMeta(uid string, namespace string, name string, owners ...string) meta.ObjectMeta {
+	o := meta.ObjectMeta{UID: types.UID(uid), Namespace: namespace, Name: name}
+	for _, owner := range owners {
+		o.OwnerReferences = append(o.OwnerReferences, meta.OwnerReference{UID: types.UID(owner)})
+	}
+	return o
+}
+
+func TestGetWorkload(t *testing.T) {
+	s := &store.Store{}
+	s.Set(&appsv1beta2.Deployment{ObjectMeta: objectMeta("deploy", "payments", "api")})
+	s.Set(&appsv1beta2.ReplicaSet{ObjectMeta: objectMeta("rs", "payments", "api-5d8f", "deploy")})
+	s.Set(&core.Pod{ObjectMeta: objectMeta("pod", "payments", "api-5d8f-x2k", "rs")})
+	s.Set(&core.Pod{ObjectMeta: objectMeta("db-0", "payments", "db-0")})
+	// policies & namespaces share the store with workloads and their names
+	s.Set(&networking.NetworkPolicy{ObjectMeta: objectMeta("policy", "payments", "api")})
+	s.Set(&networking.NetworkPolicy{ObjectMeta: objectMeta("db-policy", "payments", "db")})
+	s.Set(&core.Namespace{ObjectMeta: objectMeta("ns", "", "payments")})
+	k := &kube{store: s}
+
+	tests := []struct {
+		namespace string
+		name      string
+		want      string
+	}{
+		{namespace: "payments", name: "api", want: "deploy"},
+		{namespace: "payments", name: "db", want: "db-0"},
+		{namespace: "", name: "payments"},
+		{namespace: "billing", name: "api"},
+	}
+
+	// the store is ranged in a random order
+	for i := 0; i < 20; i++ {
+		for _, tt := range tests {
+			got := k.GetWorkload(tt.namespace, tt.name)
+			uid := ""
+			if got != nil {
+				uid = string(got.GetUID())
+			}
+			if uid != tt.want {
+				t.Fatalf("got workload %q of %s/%s, want %q", uid, tt.namespace, tt.name, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package kube
+
+import (
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceNameLabel is set on namespaces by kubernetes (1.21+)
+const NamespaceNameLabel string = "kubernetes.io/metadata.name"
+
+// podSpecificLabels are labels set per pod or per revision by controllers,
+// not selecting all pods of a workload
+var podSpecificLabels = []string{
+	"pod-template-hash",
+	"controller-revision-hash",
+	"controller-uid",
+	"job-name",
+	"statefulset.kubernetes.io/pod-name",
+	"pod-template-generation",
+}
+
+// IsWorkload check whether an object is of a workload kind owning pods,
+// the ones supported by PodSelector & PodTemplate
+func IsWorkload(object meta.Object) bool {
+	switch object.(type) {
+	case *appsv1beta2.Deployment, *appsv1beta2.DaemonSet, *appsv1beta2.ReplicaSet,
+		*batchv1.Job, *batchv1beta1.CronJob, *core.Pod:
+		return true
+	}
+
+	return false
+}
+
+// PodSelector get the selector of pods owned by a workload object,
+// nil if the object kind is not supported
+func PodSelector(object meta.Object) *meta.LabelSelector {
+	switch o := object.(type) {
+	case *appsv1beta2.Deployment:
+		return o.Spec.Selector.DeepCopy()
+	case *appsv1beta2.DaemonSet:
+		return o.Spec.Selector.DeepCopy()
+	case *appsv1beta2.ReplicaSet:
+		return o.Spec.Selector.DeepCopy()
+	case *batchv1.Job:
+		return labelSelector(o.Spec.Template.Labels)
+	case *batchv1beta1.CronJob:
+		return labelSelector(o.Spec.JobTemplate.Spec.Template.Labels)
+	case *core.Pod:
+		return labelSelector(o.Labels)
+	}
+
+	return nil
+}
+
+// PodTemplate get the template of pods owned by a workload object,
+// the pod itself for pods, nil if the object kind is not supported
+func PodTemplate(object meta.Object) *core.PodTemplateSpec {
+	switch o := object.(type) {
+	case *appsv1beta2.Deployment:
+		return o.Spec.Template.DeepCopy()
+	case *appsv1beta2.DaemonSet:
+		return o.Spec.Template.DeepCopy()
+	case *appsv1beta2.ReplicaSet:
+		return o.Spec.Template.DeepCopy()
+	case *batchv1.Job:
+		return o.Spec.Template.DeepCopy()
+	case *batchv1beta1.CronJob:
+		return o.Spec.JobTemplate.Spec.Template.DeepCopy()
+	case *core.Pod:
+		return &core.PodTemplateSpec{
+			ObjectMeta: *o.ObjectMeta.DeepCopy(),
+			Spec:       *o.Spec.DeepCopy(),
+		}
+	}
+
+	return nil
+}
+
+// labelSelector select pods by template labels, except pod specific ones
+func labelSelector(labels map[string]string) *meta.LabelSelector {
+	selector := &meta.LabelSelector{MatchLabels: map[string]string{}}
+	for k, v := range labels {
+		selector.MatchLabels[k] = v
+	}
+	for _, k := range podSpecificLabels {
+		delete(selector.MatchLabels, k)
+	}
+
+	return selector
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+type Namespaces struct {
+	client *kubernetes.Clientset
+	log    *zap.SugaredLogger
+}
+
+func NewNamespaces(client *kubernetes.Clientset) *Namespaces {
+	s := &Namespaces{
+		client: client,
+		log:    log,
+	}
+
+	return s
+}
+
+func (s *Namespaces) GetName() string {
+	return "namespaces"
+}
+
+func (s *Namespaces) GetObjects() ([]meta.Object, error) {
+	list, err := s.client.
+		CoreV1().
+		Namespaces().
+		List(meta.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error fetch namespaces / %w", err)
+	}
+
+	objects := []meta.Object{}
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+
+	return objects, nil
+}
+
+func (s *Namespaces) GetListWatch() *cache.ListWatch {
+	listWatch := cache.NewListWatchFromClient(
+		s.client.CoreV1().RESTClient(),
+		"namespaces",
+		core.NamespaceAll,
+		fields.Nothing(),
+	)
+
+	return listWatch
+}
+
+func (s *Namespaces) GetRuntimeObject() runtime.Object {
+	return new(core.Namespace)
+}
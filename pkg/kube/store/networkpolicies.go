@@ -0,0 +1,64 @@
+package store
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	core "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+type NetworkPolicies struct {
+	client *kubernetes.Clientset
+	log    *zap.SugaredLogger
+}
+
+func NewNetworkPolicies(client *kubernetes.Clientset) *NetworkPolicies {
+	s := &NetworkPolicies{
+		client: client,
+		log:    log,
+	}
+
+	return s
+}
+
+func (s *NetworkPolicies) GetName() string {
+	return "networkpolicies"
+}
+
+func (s *NetworkPolicies) GetObjects() ([]meta.Object, error) {
+	list, err := s.client.
+		NetworkingV1().
+		NetworkPolicies(core.NamespaceAll).
+		List(meta.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error fetch network policies / %w", err)
+	}
+
+	objects := []meta.Object{}
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+
+	return objects, nil
+}
+
+func (s *NetworkPolicies) GetListWatch() *cache.ListWatch {
+	listWatch := cache.NewListWatchFromClient(
+		s.client.NetworkingV1().RESTClient(),
+		"networkpolicies",
+		core.NamespaceAll,
+		fields.Nothing(),
+	)
+
+	return listWatch
+}
+
+func (s *NetworkPolicies) GetRuntimeObject() runtime.Object {
+	return new(networking.NetworkPolicy)
+}
@@ -37,6 +37,8 @@ func New(deps Deps) (*Store, error) {
 		NewDaemonSets(client),
 		NewDeployments(client),
 		NewJobs(client),
+		NewNamespaces(client),
+		NewNetworkPolicies(client),
 		NewPods(client),
 		NewReplicaSets(client),
 	}
@@ -125,6 +127,17 @@ func (s *Store) Get(uid types.UID) meta.Object {
 	return object
 }
 
+// Range call fn for each stored object, until fn return false
+func (s *Store) Range(fn func(meta.Object) bool) {
+	s.m.Range(func(_ interface{}, val interface{}) bool {
+		object, ok := val.(meta.Object)
+		if !ok {
+			return true
+		}
+		return fn(object)
+	})
+}
+
 func (s *Store) Set(object meta.Object) {
 	s.m.Store(object.GetUID(), object)
 }
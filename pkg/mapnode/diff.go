@@ -0,0 +1,175 @@
+package mapnode
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	NodeAdded   = "added"
+	NodeRemoved = "removed"
+	NodeChanged = "changed"
+
+	EdgeAdded   = "added"
+	EdgeRemoved = "removed"
+
+	// subscriberBuffer is the number of diffs a subscriber can lag behind
+	// before next diffs are merged into one for it
+	subscriberBuffer = 16
+)
+
+// NodeEvent is a node change between 2 updates,
+// Node is the previous node for removed nodes
+type NodeEvent struct {
+	Type string `json:"type"`
+	Node Node   `json:"node"`
+}
+
+// EdgeEvent is a dependency from a node to another on a port,
+// appearing or vanishing between 2 updates
+type EdgeEvent struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Port        string `json:"port"`
+}
+
+// Diff is the changes made by an update
+type Diff struct {
+	Time  time.Time   `json:"time"`
+	Nodes []NodeEvent `json:"nodes"`
+	Edges []EdgeEvent `json:"edges"`
+}
+
+// Empty check if the diff has no change
+func (d Diff) Empty() bool {
+	return len(d.Nodes) == 0 && len(d.Edges) == 0
+}
+
+// subscriber is the delivery state of a diffs channel
+type subscriber struct {
+	// since is the nodes of the last diff delivered to a lagging subscriber,
+	// nil if the subscriber is up to date
+	since map[string]Node
+}
+
+// Subscribe get diffs of next updates, until unsubscribed
+func (m *mapnode) Subscribe() (<-chan Diff, func()) {
+	ch := make(chan Diff, subscriberBuffer)
+
+	m.subMx.Lock()
+	m.subscribers[ch] = &subscriber{}
+	m.subMx.Unlock()
+
+	unsubscribe := func() {
+		m.subMx.Lock()
+		defer m.subMx.Unlock()
+		if _, ok := m.subscribers[ch]; ok {
+			delete(m.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// publish send the diff of an update to subscribers without blocking the update.
+// A subscriber with a full buffer gets the diff since its last delivered one
+// on a next update, so that it never misses a change.
+func (m *mapnode) publish(previous map[string]Node, current map[string]Node) {
+	diff := diffNodes(previous, current)
+
+	m.subMx.Lock()
+	defer m.subMx.Unlock()
+
+	for ch, sub := range m.subscribers {
+		d := diff
+		if sub.since != nil {
+			d = diffNodes(sub.since, current)
+		}
+		if d.Empty() {
+			sub.since = nil
+			continue
+		}
+
+		select {
+		case ch <- d:
+			sub.since = nil
+		default:
+			if sub.since == nil {
+				sub.since = previous
+				m.log.Warnf("delayed mesh diff for a slow subscriber: %d nodes, %d edges changed",
+					len(d.Nodes), len(d.Edges))
+			}
+		}
+	}
+}
+
+// diffNodes compare nodes and edges of 2 updates,
+// events are sorted by node id and edge
+func diffNodes(previous map[string]Node, current map[string]Node) Diff {
+	diff := Diff{
+		Time:  time.Now(),
+		Nodes: []NodeEvent{},
+		Edges: []EdgeEvent{},
+	}
+
+	for id, node := range current {
+		old, ok := previous[id]
+		switch {
+		case !ok:
+			diff.Nodes = append(diff.Nodes, NodeEvent{Type: NodeAdded, Node: node})
+		case !reflect.DeepEqual(old, node):
+			diff.Nodes = append(diff.Nodes, NodeEvent{Type: NodeChanged, Node: node})
+		}
+	}
+	for id, node := range previous {
+		if _, ok := current[id]; !ok {
+			diff.Nodes = append(diff.Nodes, NodeEvent{Type: NodeRemoved, Node: node})
+		}
+	}
+
+	sort.Slice(diff.Nodes, func(i, j int) bool {
+		return diff.Nodes[i].Node.ID < diff.Nodes[j].Node.ID
+	})
+
+	previousEdges := edgesOf(previous)
+	currentEdges := edgesOf(current)
+	for edge := range currentEdges {
+		if !previousEdges[edge] {
+			edge.Type = EdgeAdded
+			diff.Edges = append(diff.Edges, edge)
+		}
+	}
+	for edge := range previousEdges {
+		if !currentEdges[edge] {
+			edge.Type = EdgeRemoved
+			diff.Edges = append(diff.Edges, edge)
+		}
+	}
+
+	sort.Slice(diff.Edges, func(i, j int) bool {
+		a, b := diff.Edges[i], diff.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Destination != b.Destination {
+			return a.Destination < b.Destination
+		}
+		return a.Port < b.Port
+	})
+
+	return diff
+}
+
+// edgesOf get the set of edges of nodes, without event type
+func edgesOf(nodes map[string]Node) map[EdgeEvent]bool {
+	edges := make(map[EdgeEvent]bool)
+	for id, node := range nodes {
+		for _, out := range node.Outbounds {
+			edges[EdgeEvent{Source: id, Destination: out.ID, Port: out.Port}] = true
+		}
+	}
+	return edges
+}
@@ -0,0 +1,69 @@
+package mapnode
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type connections []Connection
+
+func (c *connections) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]Connection, error) {
+	return *c, nil
+}
+
+func TestPublishMergesDiffsOfSlowSubscribers(t *testing.T) {
+	conns := &connections{}
+	m, err := New(Deps{MetricsClient: conns})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+
+	// fill the buffer, then change the mesh twice while it is full
+	for i := 0; i < subscriberBuffer+2; i++ {
+		*conns = []Connection{{
+			SourceNamespace:      "default",
+			Source:               "api",
+			DestinationNamespace: "default",
+			Destination:          "db",
+			DestinationPort:      string(rune('a' + i)),
+		}}
+		if err := m.UpdateData(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < subscriberBuffer; i++ {
+		<-diffs
+	}
+	select {
+	case diff := <-diffs:
+		t.Fatalf("got diff %v before a next update", diff)
+	default:
+	}
+
+	*conns = nil
+	if err := m.UpdateData(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the merged diff starts from the last delivered update
+	diff := <-diffs
+	want := map[string]bool{
+		EdgeRemoved + " " + string(rune('a'+subscriberBuffer-1)): true,
+	}
+	if len(diff.Edges) != len(want) {
+		t.Fatalf("got edges %v, want %v", diff.Edges, want)
+	}
+	for _, edge := range diff.Edges {
+		if !want[edge.Type+" "+edge.Port] {
+			t.Errorf("got edge %v, want %v", edge, want)
+		}
+	}
+	if len(diff.Nodes) != 2 {
+		t.Errorf("got %d node events, want 2", len(diff.Nodes))
+	}
+}
@@ -0,0 +1,200 @@
+package mapnode
+
+import (
+	"sort"
+)
+
+// Reach is a node reached by a traversal, with its distance in hops
+type Reach struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster,omitempty"`
+	Depth     int    `json:"depth"`
+}
+
+// Downstream get all nodes the node depends on, directly or not,
+// up to maxDepth hops (unlimited if maxDepth <= 0)
+func (m *mapnode) Downstream(id string, maxDepth int) []Reach {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	return m.traverse(id, maxDepth, func(node Node) []string {
+		ids := make([]string, len(node.Outbounds))
+		for i, out := range node.Outbounds {
+			ids[i] = out.ID
+		}
+		return ids
+	})
+}
+
+// Upstream get all nodes depending on the node, directly or not,
+// up to maxDepth hops (unlimited if maxDepth <= 0)
+func (m *mapnode) Upstream(id string, maxDepth int) []Reach {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	return m.traverse(id, maxDepth, func(node Node) []string {
+		ids := make([]string, len(node.Inbounds))
+		for i, in := range node.Inbounds {
+			ids[i] = in.ID
+		}
+		return ids
+	})
+}
+
+// ShortestPath get node ids of the shortest path following outbounds
+// from a node to another, nil if there is no such path
+func (m *mapnode) ShortestPath(from string, to string) []string {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	if _, ok := m.nodes[from]; !ok {
+		return nil
+	}
+
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			path := []string{}
+			for ; id != ""; id = prev[id] {
+				path = append([]string{id}, path...)
+			}
+			return path
+		}
+		for _, out := range m.nodes[id].Outbounds {
+			if _, ok := prev[out.ID]; ok {
+				continue
+			}
+			prev[out.ID] = id
+			queue = append(queue, out.ID)
+		}
+	}
+
+	return nil
+}
+
+// Cycles get groups of nodes depending on each other (strongly connected
+// components with more than one node, or a node calling itself)
+func (m *mapnode) Cycles() [][]string {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	ids := make([]string, 0, len(m.nodes))
+	for id := range m.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// tarjan's algorithm
+	index := 0
+	indexes := make(map[string]int)
+	lowlinks := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := []string{}
+	cycles := [][]string{}
+
+	var connect func(id string)
+	connect = func(id string) {
+		indexes[id] = index
+		lowlinks[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		selfLoop := false
+		for _, out := range m.nodes[id].Outbounds {
+			if out.ID == id {
+				selfLoop = true
+			}
+			if _, ok := indexes[out.ID]; !ok {
+				connect(out.ID)
+				if lowlinks[out.ID] < lowlinks[id] {
+					lowlinks[id] = lowlinks[out.ID]
+				}
+			} else if onStack[out.ID] && indexes[out.ID] < lowlinks[id] {
+				lowlinks[id] = indexes[out.ID]
+			}
+		}
+
+		if lowlinks[id] != indexes[id] {
+			return
+		}
+
+		component := []string{}
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == id {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := indexes[id]; !ok {
+			connect(id)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+
+	return cycles
+}
+
+// traverse walk the graph breadth first from a node, using next to get
+// adjacent node ids. The node itself is not included in the result.
+// Callers must hold the read lock.
+func (m *mapnode) traverse(id string, maxDepth int, next func(Node) []string) []Reach {
+	reaches := []Reach{}
+	if _, ok := m.nodes[id]; !ok {
+		return reaches
+	}
+
+	depths := map[string]int{id: 0}
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		depth := depths[current]
+		if maxDepth > 0 && depth >= maxDepth {
+			continue
+		}
+		for _, adjacent := range next(m.nodes[current]) {
+			if _, ok := depths[adjacent]; ok {
+				continue
+			}
+			depths[adjacent] = depth + 1
+			queue = append(queue, adjacent)
+
+			node := m.nodes[adjacent]
+			reaches = append(reaches, Reach{
+				ID:        adjacent,
+				Name:      node.Name,
+				Namespace: node.Namespace,
+				Cluster:   node.Cluster,
+				Depth:     depth + 1,
+			})
+		}
+	}
+
+	sort.SliceStable(reaches, func(i, j int) bool {
+		if reaches[i].Depth != reaches[j].Depth {
+			return reaches[i].Depth < reaches[j].Depth
+		}
+		return reaches[i].ID < reaches[j].ID
+	})
+
+	return reaches
+}
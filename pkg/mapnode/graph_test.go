@@ -0,0 +1,124 @@
+package mapnode
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// newTestGraph update a mapnode with the graph:
+// web -> api <-> cache, api -> db, worker -> worker, worker -> db
+func newTestGraph(t *testing.T) Mapnode {
+	t.Helper()
+	conns := &connections{}
+	for _, edge := range [][2]string{
+		{"web", "api"},
+		{"api", "db"},
+		{"api", "cache"},
+		{"cache", "api"},
+		{"worker", "worker"},
+		{"worker", "db"},
+	} {
+		*conns = append(*conns, Connection{
+			SourceNamespace:      "default",
+			Source:               edge[0],
+			DestinationNamespace: "default",
+			Destination:          edge[1],
+			DestinationPort:      "80",
+		})
+	}
+
+	m, err := New(Deps{MetricsClient: conns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateData(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func idOf(name string) string {
+	return NodeID("", "default", name)
+}
+
+// reached get the names & depths of reaches
+func reached(reaches []Reach) map[string]int {
+	depths := map[string]int{}
+	for _, r := range reaches {
+		depths[r.Name] = r.Depth
+	}
+	return depths
+}
+
+func TestTraversals(t *testing.T) {
+	m := newTestGraph(t)
+
+	tests := []struct {
+		name     string
+		reaches  []Reach
+		expected map[string]int
+	}{
+		{name: "downstream", reaches: m.Downstream(idOf("web"), 0), expected: map[string]int{"api": 1, "cache": 2, "db": 2}},
+		{name: "downstream depth limit", reaches: m.Downstream(idOf("web"), 1), expected: map[string]int{"api": 1}},
+		// the cycle back to api is not reached again
+		{name: "downstream in a cycle", reaches: m.Downstream(idOf("cache"), 0), expected: map[string]int{"api": 1, "db": 2}},
+		// a self-loop does not reach the node itself
+		{name: "downstream of a self-loop", reaches: m.Downstream(idOf("worker"), 0), expected: map[string]int{"db": 1}},
+		{name: "downstream of a leaf", reaches: m.Downstream(idOf("db"), 0), expected: map[string]int{}},
+		{name: "downstream of unknown", reaches: m.Downstream(idOf("unknown"), 0), expected: map[string]int{}},
+		{name: "upstream", reaches: m.Upstream(idOf("db"), 0), expected: map[string]int{"api": 1, "worker": 1, "cache": 2, "web": 2}},
+		{name: "upstream depth limit", reaches: m.Upstream(idOf("db"), 1), expected: map[string]int{"api": 1, "worker": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reached(tt.reaches); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got reaches %v, want %v", got, tt.expected)
+			}
+			for i := 1; i < len(tt.reaches); i++ {
+				prev, r := tt.reaches[i-1], tt.reaches[i]
+				if prev.Depth > r.Depth || (prev.Depth == r.Depth && prev.ID > r.ID) {
+					t.Errorf("got reaches not sorted by depth & id: %v", tt.reaches)
+				}
+			}
+		})
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	m := newTestGraph(t)
+
+	tests := []struct {
+		from string
+		to   string
+		path []string
+	}{
+		{from: "web", to: "db", path: []string{"web", "api", "db"}},
+		{from: "cache", to: "db", path: []string{"cache", "api", "db"}},
+		{from: "web", to: "web", path: []string{"web"}},
+		{from: "db", to: "web"},
+		{from: "web", to: "worker"},
+		{from: "unknown", to: "db"},
+		{from: "web", to: "unknown"},
+	}
+
+	for _, tt := range tests {
+		var want []string
+		for _, name := range tt.path {
+			want = append(want, idOf(name))
+		}
+		if got := m.ShortestPath(idOf(tt.from), idOf(tt.to)); !reflect.DeepEqual(got, want) {
+			t.Errorf("got path %v from %s to %s, want %v", got, tt.from, tt.to, want)
+		}
+	}
+}
+
+func TestCycles(t *testing.T) {
+	m := newTestGraph(t)
+
+	want := [][]string{{idOf("api"), idOf("cache")}, {idOf("worker")}}
+	if got := m.Cycles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got cycles %v, want %v", got, want)
+	}
+}
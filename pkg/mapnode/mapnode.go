@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -21,28 +22,71 @@ type Deps struct {
 	Config        Config
 }
 
+// sources of connections, the MetricsClient feeding mapnode
+const (
+	SourcePrometheus      string = "prometheus"
+	SourceThanos          string = "thanos"
+	SourceCortex          string = "cortex"
+	SourceMimir           string = "mimir"
+	SourceVictoriaMetrics string = "victoriametrics"
+	// SourceDirect read the edges of the in-process collector: the window is
+	// cut to the last collector reset, and connections have no cluster
+	SourceDirect  string = "direct"
+	SourceStorage string = "storage"
+)
+
 type Config struct {
+	// Source of connections, selecting the MetricsClient
+	Source              string         `mapstructure:"source"`
 	GetConnectionsSince time.Duration  `mapstructure:"get_connections_since"`
 	UpdateInterval      *time.Duration `mapstructure:"update_interval"`
+	MinUpdateInterval   time.Duration  `mapstructure:"min_update_interval"`
+	// Schedule of updates, every update_interval
+	Schedule utils.ScheduleConfig `mapstructure:"schedule"`
 }
 
 type Mapnode interface {
 	UpdateData(ctx context.Context) error
-	GetNode(name string) *Node
+	RequestUpdate() *UpdateJob
+	GetUpdateJob(id string) *UpdateJob
+	WaitUpdateJob(ctx context.Context, id string) (*UpdateJob, error)
+	Subscribe() (<-chan Diff, func())
+	GetNode(id string) *Node
+	GetNodesByName(name string) []Node
 	GetAllNodes() map[string]Node
+	Downstream(id string, maxDepth int) []Reach
+	Upstream(id string, maxDepth int) []Reach
+	ShortestPath(from string, to string) []string
+	Cycles() [][]string
 	GetLastUpdated() time.Time
 	SinceLastUpdated() string
 	RunUpdateInterval(ctx context.Context)
+	// Reload apply intervals of a config, the source requiring a restart
+	Reload(config Config) error
 }
 
 type mapnode struct {
-	mx      sync.RWMutex
-	config  Config
-	log     *zap.SugaredLogger
-	metrics MetricsClient
+	mx       sync.RWMutex
+	configMx sync.RWMutex
+	config   Config
+	log      *zap.SugaredLogger
+	metrics  MetricsClient
+	reloader utils.Reloader
 
 	nodes       map[string]Node
+	names       map[string][]string
 	lastUpdated time.Time
+
+	subMx       sync.Mutex
+	subscribers map[chan Diff]*subscriber
+
+	jobMx sync.Mutex
+	// ctx bound requested updates, the context of the update interval
+	ctx    context.Context
+	job    *updateJob
+	jobs   map[string]*updateJob
+	jobIDs []string
+	jobSeq uint64
 }
 
 func MustNew(deps Deps) Mapnode {
@@ -54,6 +98,11 @@ func MustNew(deps Deps) Mapnode {
 }
 
 func New(deps Deps) (Mapnode, error) {
+	return NewWithContext(context.Background(), deps)
+}
+
+// NewWithContext create a mapnode, its first update bound to the context
+func NewWithContext(ctx context.Context, deps Deps) (Mapnode, error) {
 	if deps.Log == nil {
 		deps.Log = defaultLogger
 	}
@@ -63,32 +112,103 @@ func New(deps Deps) (Mapnode, error) {
 		log:     deps.Log,
 		metrics: deps.MetricsClient,
 		nodes:   make(map[string]Node),
+		names:   make(map[string][]string),
+		jobs:    make(map[string]*updateJob),
+		ctx:     context.Background(),
+
+		subscribers: make(map[chan Diff]*subscriber),
 	}
 
-	err := m.UpdateData(context.Background())
+	err := m.UpdateData(ctx)
 
 	return m, err
 }
 
 func (m *mapnode) RunUpdateInterval(ctx context.Context) {
-	if m.config.UpdateInterval == nil {
-		m.log.Info("disabled updating interval")
-		return
-	}
+	m.jobMx.Lock()
+	m.ctx = ctx
+	m.jobMx.Unlock()
+
+	load := func() (utils.Schedule, bool) {
+		config := m.getConfig()
+		if config.UpdateInterval == nil {
+			m.log.Info("disabled updating interval")
+			return utils.Schedule{}, false
+		}
 
-	utils.RunStateless(ctx, *m.config.UpdateInterval, func() {
+		schedule := utils.Schedule{
+			ScheduleConfig: config.Schedule,
+			Name:           "update",
+			Interval:       *config.UpdateInterval,
+		}
+		if schedule.Mode == "" {
+			schedule.Mode = utils.ScheduleFixedRate
+		}
+		return schedule, true
+	}
+	err := m.reloader.RunSchedule(ctx, load, func(ctx context.Context) error {
 		err := m.UpdateData(ctx)
 		if err != nil {
 			m.log.Error(err)
 		}
+		return err
 	})
+	if err != nil {
+		m.log.Errorf("error run updating interval / %s", err)
+	}
 }
 
-// UpdateData get connections by MetricsClient
+// Validate check intervals & the update schedule
+func (config Config) Validate() error {
+	if config.GetConnectionsSince <= 0 {
+		return fmt.Errorf("get_connections_since must be positive: %v", config.GetConnectionsSince)
+	}
+	if config.MinUpdateInterval < 0 {
+		return fmt.Errorf("min_update_interval must not be negative: %v", config.MinUpdateInterval)
+	}
+	if config.UpdateInterval != nil {
+		schedule := utils.Schedule{
+			ScheduleConfig: config.Schedule,
+			Interval:       *config.UpdateInterval,
+		}
+		if err := schedule.Validate(); err != nil {
+			return fmt.Errorf("invalid update schedule / %w", err)
+		}
+	}
+	return nil
+}
+
+// Reload apply intervals of a config, restarting the update interval
+// once its running update completes
+func (m *mapnode) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	m.configMx.Lock()
+	source := m.config.Source
+	m.config = config
+	m.config.Source = source
+	m.configMx.Unlock()
+
+	if config.Source != source {
+		m.log.Warnf("changing source from %s to %s requires a restart", source, config.Source)
+	}
+	m.reloader.Reload()
+	return nil
+}
+
+func (m *mapnode) getConfig() Config {
+	m.configMx.RLock()
+	defer m.configMx.RUnlock()
+	return m.config
+}
+
+// update get connections by MetricsClient
 // and normalize to usable information.
-func (m *mapnode) UpdateData(ctx context.Context) error {
+func (m *mapnode) update(ctx context.Context) error {
 	to := time.Now()
-	start := to.Add(-m.config.GetConnectionsSince)
+	start := to.Add(-m.getConfig().GetConnectionsSince)
 	connections, err := m.metrics.GetConnections(ctx, start, to)
 	if err != nil {
 		return err
@@ -97,21 +217,25 @@ func (m *mapnode) UpdateData(ctx context.Context) error {
 	// remove duplicated & normalize connection info
 	mapConns := make(map[string]Connection)
 	for _, conn := range connections {
-		src := regexpNodeName.ReplaceAllString(conn.Source, "")
-		dest := regexpNodeName.ReplaceAllString(conn.Destination, "")
-		key := fmt.Sprintf("%s -> %s", src, dest)
-		conn.Source = src
-		conn.Destination = dest
+		conn.Source = regexpNodeName.ReplaceAllString(conn.Source, "")
+		conn.Destination = regexpNodeName.ReplaceAllString(conn.Destination, "")
+		src := NodeID(conn.Cluster, conn.SourceNamespace, conn.Source)
+		dest := NodeID(conn.Cluster, conn.DestinationNamespace, conn.Destination)
+		key := fmt.Sprintf("%s -> %s:%s", src, dest, conn.DestinationPort)
 		mapConns[key] = conn
 	}
 
 	// create node map
 	nodes := make(map[string]Node)
+	inbounds := make(map[string]bool)
 	for _, conn := range mapConns {
+		cluster := conn.Cluster
 		src := conn.Source
 		srcNs := conn.SourceNamespace
+		srcID := NodeID(cluster, srcNs, src)
 		dest := conn.Destination
 		destNs := conn.DestinationNamespace
+		destID := NodeID(cluster, destNs, dest)
 		destPort := conn.DestinationPort
 
 		if ip := net.ParseIP(dest); ip != nil {
@@ -120,54 +244,93 @@ func (m *mapnode) UpdateData(ctx context.Context) error {
 		}
 
 		// map source to nodes
-		nodeSource, ok := nodes[src]
+		nodeSource, ok := nodes[srcID]
 		if !ok {
-			nodeSource = Node{
-				Name:      src,
-				Inbounds:  []Inbound{},
-				Outbounds: []Outbound{},
-			}
+			nodeSource = newNode(cluster, srcNs, src)
 		}
 		nodeSource.Outbounds = append(nodeSource.Outbounds, Outbound{
+			ID:        destID,
 			Name:      dest,
 			Namespace: destNs,
+			Cluster:   cluster,
 			Port:      destPort,
 		})
-		nodes[src] = nodeSource
+		nodes[srcID] = nodeSource
 
 		// map dest to nodes
-		nodeDest, ok := nodes[dest]
+		nodeDest, ok := nodes[destID]
 		if !ok {
-			nodeDest = Node{
-				Name:      dest,
-				Inbounds:  []Inbound{},
-				Outbounds: []Outbound{},
-			}
+			nodeDest = newNode(cluster, destNs, dest)
 		}
 
-		nodeDest.Inbounds = append(nodeDest.Inbounds, Inbound{
-			Name:      src,
-			Namespace: srcNs,
-		})
-		nodes[dest] = nodeDest
+		// one inbound per source, whatever ports it connects to
+		inboundKey := fmt.Sprintf("%s -> %s", srcID, destID)
+		if !inbounds[inboundKey] {
+			inbounds[inboundKey] = true
+			nodeDest.Inbounds = append(nodeDest.Inbounds, Inbound{
+				ID:        srcID,
+				Name:      src,
+				Namespace: srcNs,
+				Cluster:   cluster,
+			})
+		}
+		nodes[destID] = nodeDest
+	}
+
+	// index node ids by short name, sort bounds
+	// so that unchanged nodes are equal between updates
+	names := make(map[string][]string)
+	for id, node := range nodes {
+		names[node.Name] = append(names[node.Name], id)
+		sortBounds(node)
+	}
+	for _, ids := range names {
+		sort.Strings(ids)
 	}
 
 	m.mx.Lock()
-	defer m.mx.Unlock()
+	previous := m.nodes
 	m.nodes = nodes
+	m.names = names
 	m.lastUpdated = time.Now()
+	m.mx.Unlock()
 
-	m.log.Debugf("mapped nodes length: %d", len(m.nodes))
+	m.log.Debugf("mapped nodes length: %d", len(nodes))
+
+	m.publish(previous, nodes)
 
 	return nil
 }
 
-// GetNode get node's inbounds, outbounds information
-func (m *mapnode) GetNode(name string) *Node {
+func sortBounds(node Node) {
+	sort.Slice(node.Outbounds, func(i, j int) bool {
+		if node.Outbounds[i].ID != node.Outbounds[j].ID {
+			return node.Outbounds[i].ID < node.Outbounds[j].ID
+		}
+		return node.Outbounds[i].Port < node.Outbounds[j].Port
+	})
+	sort.Slice(node.Inbounds, func(i, j int) bool {
+		return node.Inbounds[i].ID < node.Inbounds[j].ID
+	})
+}
+
+func newNode(cluster string, namespace string, name string) Node {
+	return Node{
+		ID:        NodeID(cluster, namespace, name),
+		Name:      name,
+		Namespace: namespace,
+		Cluster:   cluster,
+		Inbounds:  []Inbound{},
+		Outbounds: []Outbound{},
+	}
+}
+
+// GetNode get node's inbounds, outbounds information by node id
+func (m *mapnode) GetNode(id string) *Node {
 	m.mx.RLock()
 	defer m.mx.RUnlock()
 
-	node, ok := m.nodes[name]
+	node, ok := m.nodes[id]
 	if !ok {
 		return nil
 	}
@@ -175,6 +338,21 @@ func (m *mapnode) GetNode(name string) *Node {
 	return &node
 }
 
+// GetNodesByName get all nodes having the short name,
+// across namespaces and clusters, sorted by id
+func (m *mapnode) GetNodesByName(name string) []Node {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	ids := m.names[name]
+	nodes := make([]Node, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, m.nodes[id])
+	}
+
+	return nodes
+}
+
 // GetAllNodes return a clone mapped nodes
 func (m *mapnode) GetAllNodes() map[string]Node {
 	m.mx.RLock()
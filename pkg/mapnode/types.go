@@ -3,6 +3,7 @@ package mapnode
 import (
 	"context"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -11,7 +12,10 @@ var (
 )
 
 type Node struct {
+	ID        string    `json:"id"`
 	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Cluster   string    `json:"cluster,omitempty"`
 	Outbounds Outbounds `json:"outbounds"`
 	Inbounds  Inbounds  `json:"inbounds"`
 }
@@ -20,17 +24,22 @@ type Inbounds []Inbound
 type Outbounds []Outbound
 
 type Outbound struct {
+	ID        string `json:"id"`
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster,omitempty"`
 	Port      string `json:"port"`
 }
 
 type Inbound struct {
+	ID        string `json:"id"`
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster,omitempty"`
 }
 
 type Connection struct {
+	Cluster              string `json:"cluster,omitempty"`
 	Source               string `json:"source"`
 	SourceNamespace      string `json:"source_namespace"`
 	Destination          string `json:"destination"`
@@ -41,3 +50,25 @@ type Connection struct {
 type MetricsClient interface {
 	GetConnections(ctx context.Context, start time.Time, end time.Time) ([]Connection, error)
 }
+
+// NodeID build the identity of a node: "namespace/name",
+// prefixed by "cluster/" if cluster is set
+func NodeID(cluster string, namespace string, name string) string {
+	parts := []string{namespace, name}
+	if cluster != "" {
+		parts = append([]string{cluster}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// SplitNodeID get cluster, namespace and name of a node id
+func SplitNodeID(id string) (cluster string, namespace string, name string) {
+	parts := strings.Split(id, "/")
+	switch len(parts) {
+	case 1:
+		return "", "", parts[0]
+	case 2:
+		return "", parts[0], parts[1]
+	}
+	return parts[0], parts[1], strings.Join(parts[2:], "/")
+}
@@ -0,0 +1,142 @@
+package mapnode
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	UpdateRunning = "running"
+	UpdateDone    = "done"
+	UpdateFailed  = "failed"
+
+	// maxUpdateJobs is the number of finished jobs kept for status lookups
+	maxUpdateJobs = 100
+)
+
+// UpdateJob is a snapshot of a data update
+type UpdateJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+type updateJob struct {
+	UpdateJob
+	err  error
+	done chan struct{}
+}
+
+// UpdateData update data and wait for it to complete.
+// Concurrent calls share the same running update, bound to the context
+// of the call starting it. Each call waits until its own context is done.
+func (m *mapnode) UpdateData(ctx context.Context) error {
+	job := m.startUpdate(ctx)
+	select {
+	case <-job.done:
+		return job.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RequestUpdate start an update unless the data is updated
+// more recently than MinUpdateInterval. It returns the running update,
+// or nil if the data is fresh enough. Requested updates outlive the requests,
+// bound to the context of the update interval.
+func (m *mapnode) RequestUpdate() *UpdateJob {
+	m.jobMx.Lock()
+	running := m.job != nil
+	ctx := m.ctx
+	m.jobMx.Unlock()
+
+	if !running && time.Since(m.GetLastUpdated()) < m.getConfig().MinUpdateInterval {
+		return nil
+	}
+
+	job := m.startUpdate(ctx)
+	return m.GetUpdateJob(job.ID)
+}
+
+// GetUpdateJob get an update by id, nil if not found
+func (m *mapnode) GetUpdateJob(id string) *UpdateJob {
+	m.jobMx.Lock()
+	defer m.jobMx.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil
+	}
+
+	snapshot := job.UpdateJob
+	return &snapshot
+}
+
+// WaitUpdateJob wait for an update to complete and get it
+func (m *mapnode) WaitUpdateJob(ctx context.Context, id string) (*UpdateJob, error) {
+	m.jobMx.Lock()
+	job, ok := m.jobs[id]
+	m.jobMx.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("not found update job: %s", id)
+	}
+
+	select {
+	case <-job.done:
+		return m.GetUpdateJob(id), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startUpdate get the running update, or start a new one with a context
+func (m *mapnode) startUpdate(ctx context.Context) *updateJob {
+	m.jobMx.Lock()
+	defer m.jobMx.Unlock()
+
+	if m.job != nil {
+		return m.job
+	}
+
+	m.jobSeq++
+	job := &updateJob{
+		UpdateJob: UpdateJob{
+			ID:        fmt.Sprintf("%d-%d", time.Now().Unix(), m.jobSeq),
+			Status:    UpdateRunning,
+			StartedAt: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	m.job = job
+	m.jobs[job.ID] = job
+	m.jobIDs = append(m.jobIDs, job.ID)
+	if len(m.jobIDs) > maxUpdateJobs {
+		delete(m.jobs, m.jobIDs[0])
+		m.jobIDs = m.jobIDs[1:]
+	}
+
+	go m.runUpdate(ctx, job)
+
+	return job
+}
+
+func (m *mapnode) runUpdate(ctx context.Context, job *updateJob) {
+	err := m.update(ctx)
+
+	m.jobMx.Lock()
+	defer m.jobMx.Unlock()
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Status = UpdateDone
+	if err != nil {
+		job.err = err
+		job.Status = UpdateFailed
+		job.Error = err.Error()
+	}
+	m.job = nil
+	close(job.done)
+}
@@ -0,0 +1,80 @@
+package mapnode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blocking is a MetricsClient blocking until the context is done
+type blocking struct {
+	started chan struct{}
+}
+
+func (b *blocking) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]Connection, error) {
+	b.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestUpdateBoundToTheStartingContext(t *testing.T) {
+	metrics := &blocking{started: make(chan struct{}, 1)}
+	m := &mapnode{
+		log:     defaultLogger,
+		metrics: metrics,
+		nodes:   make(map[string]Node),
+		names:   make(map[string][]string),
+		jobs:    make(map[string]*updateJob),
+		ctx:     context.Background(),
+
+		subscribers: make(map[chan Diff]*subscriber),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- m.UpdateData(ctx)
+	}()
+	<-metrics.started
+
+	// a waiter leaves on its own context, without cancelling the update
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer waitCancel()
+	if err := m.UpdateData(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got waiter error %v, want deadline exceeded", err)
+	}
+	first := m.RequestUpdate()
+	if first == nil || first.Status != UpdateRunning {
+		t.Fatalf("got job %+v, want running", first)
+	}
+
+	// the starting context cancels the update
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want canceled", err)
+	}
+	first, err := m.WaitUpdateJob(context.Background(), first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != UpdateFailed {
+		t.Errorf("got job %+v, want failed", first)
+	}
+
+	// a requested update is bound to the update interval context instead
+	intervalCtx, intervalCancel := context.WithCancel(context.Background())
+	m.ctx = intervalCtx
+	job := m.RequestUpdate()
+	if job == nil {
+		t.Fatal("got no update")
+	}
+	<-metrics.started
+	if job.ID == first.ID || job.Status != UpdateRunning {
+		t.Errorf("got job %+v, want a new running one", job)
+	}
+	intervalCancel()
+	if job, _ = m.WaitUpdateJob(context.Background(), job.ID); job.Status != UpdateFailed {
+		t.Errorf("got job %+v, want failed", job)
+	}
+}
@@ -0,0 +1,143 @@
+package meshexport
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func renderDOT(w io.Writer, g *graph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph mesh {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, "  node [shape=box];")
+	for i, gr := range g.groups {
+		fmt.Fprintf(bw, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(bw, "    label=%s;\n", strconv.Quote(gr.label))
+		for _, node := range gr.nodes {
+			fmt.Fprintf(bw, "    %s [label=%s];\n", strconv.Quote(node.ID), strconv.Quote(node.Name))
+		}
+		fmt.Fprintln(bw, "  }")
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "  %s -> %s [label=%s];\n",
+			strconv.Quote(e.source), strconv.Quote(e.target), strconv.Quote(e.label()))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func renderMermaid(w io.Writer, g *graph) error {
+	// mermaid ids must be plain words, so nodes are numbered
+	ids := map[string]string{}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "flowchart LR")
+	for i, gr := range g.groups {
+		fmt.Fprintf(bw, "  subgraph g%d[%s]\n", i, mermaidText(gr.label))
+		for _, node := range gr.nodes {
+			ids[node.ID] = fmt.Sprintf("n%d", len(ids))
+			fmt.Fprintf(bw, "    %s[%s]\n", ids[node.ID], mermaidText(node.Name))
+		}
+		fmt.Fprintln(bw, "  end")
+	}
+	for _, e := range g.edges {
+		if label := e.label(); label != "" {
+			fmt.Fprintf(bw, "  %s -->|%s| %s\n", ids[e.source], mermaidText(label), ids[e.target])
+		} else {
+			fmt.Fprintf(bw, "  %s --> %s\n", ids[e.source], ids[e.target])
+		}
+	}
+	return bw.Flush()
+}
+
+func mermaidText(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+func renderGraphML(w io.Writer, g *graph) error {
+	bw := bufio.NewWriter(w)
+	esc := func(s string) string {
+		b := strings.Builder{}
+		_ = xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	fmt.Fprintln(bw, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(bw, `  <key id="name" for="node" attr.name="name" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="namespace" for="node" attr.name="namespace" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="cluster" for="node" attr.name="cluster" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="port" for="edge" attr.name="port" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <graph id="mesh" edgedefault="directed">`)
+	for i, gr := range g.groups {
+		fmt.Fprintf(bw, "    <node id=\"group:%d\">\n", i)
+		fmt.Fprintf(bw, "      <data key=\"name\">%s</data>\n", esc(gr.label))
+		fmt.Fprintf(bw, "      <graph id=\"group:%d:\" edgedefault=\"directed\">\n", i)
+		for _, node := range gr.nodes {
+			fmt.Fprintf(bw, "        <node id=\"%s\">\n", esc(node.ID))
+			fmt.Fprintf(bw, "          <data key=\"name\">%s</data>\n", esc(node.Name))
+			fmt.Fprintf(bw, "          <data key=\"namespace\">%s</data>\n", esc(node.Namespace))
+			if node.Cluster != "" {
+				fmt.Fprintf(bw, "          <data key=\"cluster\">%s</data>\n", esc(node.Cluster))
+			}
+			fmt.Fprintln(bw, "        </node>")
+		}
+		fmt.Fprintln(bw, "      </graph>")
+		fmt.Fprintln(bw, "    </node>")
+	}
+	for i, e := range g.edges {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, esc(e.source), esc(e.target))
+		fmt.Fprintf(bw, "      <data key=\"port\">%s</data>\n", esc(e.label()))
+		fmt.Fprintln(bw, "    </edge>")
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
+
+type cytoscapeElements struct {
+	Nodes []cytoscapeElement `json:"nodes"`
+	Edges []cytoscapeElement `json:"edges"`
+}
+
+type cytoscapeElement struct {
+	Data map[string]string `json:"data"`
+}
+
+func renderCytoscape(w io.Writer, g *graph) error {
+	elements := cytoscapeElements{
+		Nodes: []cytoscapeElement{},
+		Edges: []cytoscapeElement{},
+	}
+	for _, gr := range g.groups {
+		parent := "group:" + gr.id
+		elements.Nodes = append(elements.Nodes, cytoscapeElement{Data: map[string]string{
+			"id":    parent,
+			"label": gr.label,
+		}})
+		for _, node := range gr.nodes {
+			elements.Nodes = append(elements.Nodes, cytoscapeElement{Data: map[string]string{
+				"id":        node.ID,
+				"label":     node.Name,
+				"namespace": node.Namespace,
+				"cluster":   node.Cluster,
+				"parent":    parent,
+			}})
+		}
+	}
+	for _, e := range g.edges {
+		elements.Edges = append(elements.Edges, cytoscapeElement{Data: map[string]string{
+			"id":     e.source + " -> " + e.target,
+			"source": e.source,
+			"target": e.target,
+			"label":  e.label(),
+		}})
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"elements": elements,
+	})
+}
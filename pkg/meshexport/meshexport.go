@@ -0,0 +1,159 @@
+// Package meshexport render mapnode graphs to diagram and graph tool formats.
+// Namespaces become clusters/subgraphs and ports become edge labels.
+package meshexport
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+type Format string
+
+const (
+	FormatDOT       Format = "dot"
+	FormatMermaid   Format = "mermaid"
+	FormatGraphML   Format = "graphml"
+	FormatCytoscape Format = "cytoscape"
+)
+
+var (
+	Formats = []Format{FormatDOT, FormatMermaid, FormatGraphML, FormatCytoscape}
+
+	contentTypes = map[Format]string{
+		FormatDOT:       "text/vnd.graphviz; charset=utf-8",
+		FormatMermaid:   "text/vnd.mermaid; charset=utf-8",
+		FormatGraphML:   "application/graphml+xml; charset=utf-8",
+		FormatCytoscape: "application/json; charset=utf-8",
+	}
+
+	renderers = map[Format]func(io.Writer, *graph) error{
+		FormatDOT:       renderDOT,
+		FormatMermaid:   renderMermaid,
+		FormatGraphML:   renderGraphML,
+		FormatCytoscape: renderCytoscape,
+	}
+)
+
+// ParseFormat validate a format name
+func ParseFormat(s string) (Format, error) {
+	format := Format(strings.ToLower(s))
+	if _, ok := renderers[format]; !ok {
+		return "", fmt.Errorf("unsupported format: %s (supported: %v)", s, Formats)
+	}
+	return format, nil
+}
+
+// FormatFromAccept get the export format requested by an Accept header,
+// empty if none of the media types are a graph format
+func FormatFromAccept(accept string) Format {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		for format, contentType := range contentTypes {
+			if format == FormatCytoscape {
+				// plain json is the default response
+				continue
+			}
+			if strings.HasPrefix(contentType, mediaType+";") {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// ContentType get the media type of the format
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// Render write the nodes graph in the format
+func Render(w io.Writer, format Format, nodes map[string]mapnode.Node) error {
+	render, ok := renderers[format]
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	return render(w, newGraph(nodes))
+}
+
+// graph is a sorted, render friendly view of mapnode nodes
+type graph struct {
+	groups []group
+	edges  []edge
+}
+
+type group struct {
+	id    string
+	label string
+	nodes []mapnode.Node
+}
+
+type edge struct {
+	source string
+	target string
+	ports  []string
+}
+
+func (e edge) label() string {
+	return strings.Join(e.ports, ", ")
+}
+
+func newGraph(nodes map[string]mapnode.Node) *graph {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	g := &graph{}
+	groupIndexes := map[string]int{}
+	edgeIndexes := map[string]int{}
+	for _, id := range ids {
+		node := nodes[id]
+		groupID := mapnode.NodeID(node.Cluster, node.Namespace, "")
+		groupID = strings.TrimSuffix(groupID, "/")
+		i, ok := groupIndexes[groupID]
+		if !ok {
+			i = len(g.groups)
+			groupIndexes[groupID] = i
+			g.groups = append(g.groups, group{id: groupID, label: groupID})
+		}
+		g.groups[i].nodes = append(g.groups[i].nodes, node)
+
+		for _, out := range node.Outbounds {
+			// edges leaving the rendered nodes, e.g. filtered out, are dropped
+			// so that all edges reference declared nodes
+			if _, ok := nodes[out.ID]; !ok {
+				continue
+			}
+			key := id + " -> " + out.ID
+			j, ok := edgeIndexes[key]
+			if !ok {
+				j = len(g.edges)
+				edgeIndexes[key] = j
+				g.edges = append(g.edges, edge{source: id, target: out.ID})
+			}
+			if out.Port != "" {
+				g.edges[j].ports = append(g.edges[j].ports, out.Port)
+			}
+		}
+	}
+
+	sort.Slice(g.groups, func(i, j int) bool {
+		return g.groups[i].id < g.groups[j].id
+	})
+	sort.Slice(g.edges, func(i, j int) bool {
+		if g.edges[i].source != g.edges[j].source {
+			return g.edges[i].source < g.edges[j].source
+		}
+		return g.edges[i].target < g.edges[j].target
+	})
+	for i := range g.edges {
+		sort.Strings(g.edges[i].ports)
+	}
+
+	return g
+}
@@ -0,0 +1,213 @@
+package meshql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// listCostFactor is the estimated number of items of a list field,
+// used to compute the complexity of its selections
+const listCostFactor = 10
+
+// capHops cap the depth argument of graph traversals to the max depth,
+// traversals being unlimited if 0 only without max depth
+func capHops(depth int, maxDepth int) int {
+	if maxDepth > 0 && (depth <= 0 || depth > maxDepth) {
+		return maxDepth
+	}
+	return depth
+}
+
+// hops get the number of hops of a traversal of a depth
+func (q *meshql) hops(depth int) int {
+	return capHops(depth, q.config.MaxDepth)
+}
+
+// checkLimits compute depth and complexity of the request operations,
+// introspection fields are not counted
+func (q *meshql) checkLimits(req Request) error {
+	if q.config.MaxDepth <= 0 && q.config.MaxComplexity <= 0 {
+		return nil
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		// let the executor report syntax errors
+		return nil
+	}
+
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if req.OperationName != "" && (op.Name == nil || op.Name.Value != req.OperationName) {
+			continue
+		}
+
+		w := &limitWalker{
+			schema:    q.schema,
+			fragments: fragments,
+			variables: req.Variables,
+			maxDepth:  q.config.MaxDepth,
+		}
+		depth, cost := w.selectionSet(q.schema.QueryType(), op.SelectionSet, map[string]bool{})
+		if q.config.MaxDepth > 0 && depth > q.config.MaxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit %d", depth, q.config.MaxDepth)
+		}
+		if q.config.MaxComplexity > 0 && cost > q.config.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit %d", cost, q.config.MaxComplexity)
+		}
+	}
+
+	return nil
+}
+
+type limitWalker struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	maxDepth  int
+}
+
+// selectionSet get depth and cost of the selections on a parent type,
+// visited holds fragments being expanded to stop on fragment cycles
+func (w *limitWalker) selectionSet(parent graphql.Type, set *ast.SelectionSet, visited map[string]bool) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	maxDepth, cost := 0, 0
+	add := func(depth int, c int) {
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		cost += c
+	}
+
+	for _, selection := range set.Selections {
+		switch sel := selection.(type) {
+		case *ast.Field:
+			name := sel.Name.Value
+			if strings.HasPrefix(name, "__") {
+				continue
+			}
+			fieldType, isList := w.fieldType(parent, name)
+			depth, childCost := w.selectionSet(fieldType, sel.SelectionSet, visited)
+			if hops, ok := w.traversal(parent, sel); ok {
+				// each hop of a traversal may reach a list of nodes
+				childCost *= listCostFactor * hops
+			} else if isList {
+				childCost *= listCostFactor
+			}
+			add(depth+1, childCost+1)
+
+		case *ast.InlineFragment:
+			typ := parent
+			if sel.TypeCondition != nil {
+				typ = w.schema.Type(sel.TypeCondition.Name.Value)
+			}
+			add(w.selectionSet(typ, sel.SelectionSet, visited))
+
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			fragment, ok := w.fragments[name]
+			if !ok || visited[name] {
+				continue
+			}
+			visited[name] = true
+			typ := w.schema.Type(fragment.TypeCondition.Name.Value)
+			add(w.selectionSet(typ, fragment.SelectionSet, visited))
+			delete(visited, name)
+		}
+	}
+
+	return maxDepth, cost
+}
+
+// fieldType get the named type of a field and whether it is a list
+func (w *limitWalker) fieldType(parent graphql.Type, name string) (graphql.Type, bool) {
+	obj, ok := parent.(*graphql.Object)
+	if !ok {
+		return nil, false
+	}
+	field, ok := obj.Fields()[name]
+	if !ok {
+		return nil, false
+	}
+
+	isList := false
+	typ := field.Type
+	for {
+		switch t := typ.(type) {
+		case *graphql.NonNull:
+			typ = t.OfType
+			continue
+		case *graphql.List:
+			isList = true
+			typ = t.OfType
+			continue
+		}
+		return typ, isList
+	}
+}
+
+// traversal get the hops of a field traversing the graph, the ones with
+// a depth argument, as capped when resolved. Unlimited traversals count
+// listCostFactor hops.
+func (w *limitWalker) traversal(parent graphql.Type, sel *ast.Field) (int, bool) {
+	obj, ok := parent.(*graphql.Object)
+	if !ok {
+		return 0, false
+	}
+	field, ok := obj.Fields()[sel.Name.Value]
+	if !ok {
+		return 0, false
+	}
+
+	depth, found := 0, false
+	for _, arg := range field.Args {
+		if arg.Name() == "depth" {
+			depth, _ = arg.DefaultValue.(int)
+			found = true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+
+	for _, arg := range sel.Arguments {
+		if arg.Name.Value != "depth" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			depth, _ = strconv.Atoi(v.Value)
+		case *ast.Variable:
+			switch value := w.variables[v.Name.Value].(type) {
+			case float64:
+				depth = int(value)
+			case int:
+				depth = value
+			}
+		}
+	}
+
+	hops := capHops(depth, w.maxDepth)
+	if hops <= 0 {
+		hops = listCostFactor
+	}
+	return hops, true
+}
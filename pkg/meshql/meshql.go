@@ -0,0 +1,89 @@
+// Package meshql is a GraphQL API over the mapnode graph,
+// so that each consumer fetches exactly the shape it needs.
+package meshql
+
+import (
+	"context"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"go.uber.org/zap"
+)
+
+var defaultLogger = utils.MustGetLogger("meshql")
+
+type Deps struct {
+	Log     *zap.SugaredLogger
+	Mapnode mapnode.Mapnode
+	Config  Config
+}
+
+type Config struct {
+	MaxDepth      int `mapstructure:"max_depth"`
+	MaxComplexity int `mapstructure:"max_complexity"`
+}
+
+// Request is a GraphQL request, as posted by GraphQL clients
+type Request struct {
+	Query         string                 `json:"query" query:"query"`
+	OperationName string                 `json:"operationName" query:"operationName"`
+	Variables     map[string]interface{} `json:"variables" query:"-"`
+}
+
+type MeshQL interface {
+	Execute(ctx context.Context, req Request) *graphql.Result
+}
+
+type meshql struct {
+	config  Config
+	log     *zap.SugaredLogger
+	mapnode mapnode.Mapnode
+	schema  graphql.Schema
+}
+
+func MustNew(deps Deps) MeshQL {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (MeshQL, error) {
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+
+	q := &meshql{
+		config:  deps.Config,
+		log:     deps.Log,
+		mapnode: deps.Mapnode,
+	}
+
+	schema, err := q.newSchema()
+	if err != nil {
+		return nil, err
+	}
+	q.schema = schema
+
+	return q, nil
+}
+
+// Execute check the request limits then execute it
+func (q *meshql) Execute(ctx context.Context, req Request) *graphql.Result {
+	if err := q.checkLimits(req); err != nil {
+		return &graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())},
+		}
+	}
+
+	return graphql.Do(graphql.Params{
+		Schema:         q.schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        ctx,
+	})
+}
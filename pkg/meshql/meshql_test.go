@@ -0,0 +1,148 @@
+package meshql
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+// graph is a mapnode of a single node, recording traversal depths
+type graph struct {
+	mapnode.Mapnode
+	depths []int
+}
+
+func (g *graph) GetNode(id string) *mapnode.Node {
+	return &mapnode.Node{ID: id, Name: id}
+}
+
+func (g *graph) GetAllNodes() map[string]mapnode.Node {
+	return map[string]mapnode.Node{"a": {ID: "a", Name: "a"}}
+}
+
+func (g *graph) Downstream(id string, maxDepth int) []mapnode.Reach {
+	g.depths = append(g.depths, maxDepth)
+	return nil
+}
+
+func newTestMeshQL(t *testing.T, config Config) (*meshql, *graph) {
+	t.Helper()
+	g := &graph{}
+	q, err := New(Deps{Mapnode: g, Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q.(*meshql), g
+}
+
+func TestCheckLimits(t *testing.T) {
+	q, _ := newTestMeshQL(t, Config{MaxDepth: 4, MaxComplexity: 300})
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		err       string
+	}{
+		{name: "within limits", query: `{ nodes { id outbounds { port } } }`},
+		{name: "introspection", query: `{ __schema { types { name fields { name type { name } } } } }`},
+		{
+			name:  "over deep",
+			query: `{ node(id: "a") { outbounds { node { outbounds { node { id } } } } } }`,
+			err:   "query depth 6 exceeds the limit 4",
+		},
+		{
+			name:  "over complex lists",
+			query: `{ nodes { outbounds { port } inbounds { id } ports { outbounds { port } } } }`,
+			err:   "query complexity 1331 exceeds",
+		},
+		{
+			// an unlimited traversal per node counts the max depth hops
+			name:  "unlimited traversal",
+			query: `{ nodes { downstream { id } } }`,
+			err:   "query complexity 411 exceeds",
+		},
+		{name: "short traversal", query: `{ nodes { downstream(depth: 1) { id } } }`},
+		{
+			name:      "traversal depth variable",
+			query:     `query($depth: Int) { nodes { downstream(depth: $depth) { id } } }`,
+			variables: map[string]interface{}{"depth": float64(3)},
+			err:       "query complexity 311 exceeds",
+		},
+		{
+			name:  "over deep fragment",
+			query: `{ node(id: "a") { ...Outbounds } } fragment Outbounds on Node { outbounds { node { outbounds { node { id } } } } }`,
+			err:   "query depth 6 exceeds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := q.checkLimits(Request{Query: tt.query, Variables: tt.variables})
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("got error %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckLimitsFragmentCycle(t *testing.T) {
+	q, _ := newTestMeshQL(t, Config{MaxDepth: 8, MaxComplexity: 10000})
+	query := `
+		{ node(id: "a") { ...A } }
+		fragment A on Node { id outbounds { node { ...B } } }
+		fragment B on Node { inbounds { node { ...A } } }
+	`
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.checkLimits(Request{Query: query})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("got limits check looping on a fragment cycle")
+	}
+
+	// the executor rejects the cycle itself
+	if result := q.Execute(context.Background(), Request{Query: query}); !result.HasErrors() {
+		t.Error("got no error of a fragment cycle")
+	}
+}
+
+func TestTraversalDepthCapped(t *testing.T) {
+	q, g := newTestMeshQL(t, Config{MaxDepth: 4})
+
+	for _, query := range []string{
+		`{ node(id: "a") { downstream { id } } }`,
+		`{ node(id: "a") { downstream(depth: 2) { id } } }`,
+		`{ node(id: "a") { downstream(depth: 100) { id } } }`,
+	} {
+		if result := q.Execute(context.Background(), Request{Query: query}); result.HasErrors() {
+			t.Fatalf("got errors %v", result.Errors)
+		}
+	}
+
+	want := []int{4, 2, 4}
+	if len(g.depths) != len(want) {
+		t.Fatalf("got depths %v, want %v", g.depths, want)
+	}
+	for i := range want {
+		if g.depths[i] != want[i] {
+			t.Errorf("got depths %v, want %v", g.depths, want)
+			break
+		}
+	}
+}
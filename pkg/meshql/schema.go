@@ -0,0 +1,329 @@
+package meshql
+
+import (
+	"sort"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	directionOut  = "OUT"
+	directionIn   = "IN"
+	directionBoth = "BOTH"
+)
+
+// portGroup is a node outbounds to a same port
+type portGroup struct {
+	Port      string
+	Outbounds []mapnode.Outbound
+}
+
+func (q *meshql) newSchema() (graphql.Schema, error) {
+	var nodeType *graphql.Object
+
+	// nodeField resolve the node of an edge or a reach by its id
+	nodeField := func(id func(interface{}) string) *graphql.Field {
+		return &graphql.Field{
+			Type: nodeType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return q.mapnode.GetNode(id(p.Source)), nil
+			},
+		}
+	}
+
+	outboundType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Outbound",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        stringField(func(s interface{}) string { return s.(mapnode.Outbound).ID }),
+				"name":      stringField(func(s interface{}) string { return s.(mapnode.Outbound).Name }),
+				"namespace": stringField(func(s interface{}) string { return s.(mapnode.Outbound).Namespace }),
+				"cluster":   stringField(func(s interface{}) string { return s.(mapnode.Outbound).Cluster }),
+				"port":      stringField(func(s interface{}) string { return s.(mapnode.Outbound).Port }),
+				"node":      nodeField(func(s interface{}) string { return s.(mapnode.Outbound).ID }),
+			}
+		}),
+	})
+
+	inboundType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Inbound",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        stringField(func(s interface{}) string { return s.(mapnode.Inbound).ID }),
+				"name":      stringField(func(s interface{}) string { return s.(mapnode.Inbound).Name }),
+				"namespace": stringField(func(s interface{}) string { return s.(mapnode.Inbound).Namespace }),
+				"cluster":   stringField(func(s interface{}) string { return s.(mapnode.Inbound).Cluster }),
+				"node":      nodeField(func(s interface{}) string { return s.(mapnode.Inbound).ID }),
+			}
+		}),
+	})
+
+	portGroupType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PortGroup",
+		Fields: graphql.Fields{
+			"port": stringField(func(s interface{}) string { return s.(portGroup).Port }),
+			"outbounds": &graphql.Field{
+				Type: graphql.NewList(outboundType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(portGroup).Outbounds, nil
+				},
+			},
+		},
+	})
+
+	reachType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Reach",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        stringField(func(s interface{}) string { return s.(mapnode.Reach).ID }),
+				"name":      stringField(func(s interface{}) string { return s.(mapnode.Reach).Name }),
+				"namespace": stringField(func(s interface{}) string { return s.(mapnode.Reach).Namespace }),
+				"cluster":   stringField(func(s interface{}) string { return s.(mapnode.Reach).Cluster }),
+				"depth": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(mapnode.Reach).Depth, nil
+					},
+				},
+				"node": nodeField(func(s interface{}) string { return s.(mapnode.Reach).ID }),
+			}
+		}),
+	})
+
+	directionType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Direction",
+		Values: graphql.EnumValueConfigMap{
+			directionOut:  &graphql.EnumValueConfig{Value: directionOut, Description: "following outbounds"},
+			directionIn:   &graphql.EnumValueConfig{Value: directionIn, Description: "following inbounds"},
+			directionBoth: &graphql.EnumValueConfig{Value: directionBoth, Description: "following both"},
+		},
+	})
+
+	traversalArgs := graphql.FieldConfigArgument{
+		"depth": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: 0,
+			Description:  "maximum number of hops, capped by the max query depth, unlimited if 0 without it",
+		},
+	}
+
+	nodeType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Node",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        stringField(func(s interface{}) string { return nodeOf(s).ID }),
+				"name":      stringField(func(s interface{}) string { return nodeOf(s).Name }),
+				"namespace": stringField(func(s interface{}) string { return nodeOf(s).Namespace }),
+				"cluster":   stringField(func(s interface{}) string { return nodeOf(s).Cluster }),
+				"outbounds": &graphql.Field{
+					Type: graphql.NewList(outboundType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return []mapnode.Outbound(nodeOf(p.Source).Outbounds), nil
+					},
+				},
+				"inbounds": &graphql.Field{
+					Type: graphql.NewList(inboundType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return []mapnode.Inbound(nodeOf(p.Source).Inbounds), nil
+					},
+				},
+				"ports": &graphql.Field{
+					Type:        graphql.NewList(portGroupType),
+					Description: "outbounds grouped by port",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return groupByPort(nodeOf(p.Source).Outbounds), nil
+					},
+				},
+				"downstream": &graphql.Field{
+					Type:        graphql.NewList(reachType),
+					Description: "nodes the node depends on, directly or not",
+					Args:        traversalArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return q.mapnode.Downstream(nodeOf(p.Source).ID, q.hops(p.Args["depth"].(int))), nil
+					},
+				},
+				"upstream": &graphql.Field{
+					Type:        graphql.NewList(reachType),
+					Description: "nodes depending on the node, directly or not",
+					Args:        traversalArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return q.mapnode.Upstream(nodeOf(p.Source).ID, q.hops(p.Args["depth"].(int))), nil
+					},
+				},
+				"neighbors": &graphql.Field{
+					Type:        graphql.NewList(reachType),
+					Description: "nodes within some hops in a direction",
+					Args: graphql.FieldConfigArgument{
+						"direction": &graphql.ArgumentConfig{Type: directionType, DefaultValue: directionBoth},
+						"depth":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						id := nodeOf(p.Source).ID
+						depth := p.Args["depth"].(int)
+						return q.neighbors(id, p.Args["direction"].(string), depth), nil
+					},
+				},
+			}
+		}),
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"node": &graphql.Field{
+				Type:        nodeType,
+				Description: "a node by id, or by name and namespace",
+				Args: graphql.FieldConfigArgument{
+					"id":        &graphql.ArgumentConfig{Type: graphql.String},
+					"name":      &graphql.ArgumentConfig{Type: graphql.String},
+					"namespace": &graphql.ArgumentConfig{Type: graphql.String},
+					"cluster":   &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, _ := p.Args["id"].(string)
+					if id == "" {
+						name, _ := p.Args["name"].(string)
+						namespace, _ := p.Args["namespace"].(string)
+						cluster, _ := p.Args["cluster"].(string)
+						id = mapnode.NodeID(cluster, namespace, name)
+					}
+					return q.mapnode.GetNode(id), nil
+				},
+			},
+			"nodes": &graphql.Field{
+				Type:        graphql.NewList(nodeType),
+				Description: "nodes sorted by id, optionally by short name or namespace",
+				Args: graphql.FieldConfigArgument{
+					"name":      &graphql.ArgumentConfig{Type: graphql.String},
+					"namespace": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					name, _ := p.Args["name"].(string)
+					namespace, _ := p.Args["namespace"].(string)
+					return q.nodes(name, namespace), nil
+				},
+			},
+			"cycles": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewList(graphql.String)),
+				Description: "groups of node ids depending on each other",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return q.mapnode.Cycles(), nil
+				},
+			},
+			"lastUpdated": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return q.mapnode.GetLastUpdated(), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: queryType,
+	})
+}
+
+func (q *meshql) nodes(name string, namespace string) []mapnode.Node {
+	var nodes []mapnode.Node
+	if name != "" {
+		nodes = q.mapnode.GetNodesByName(name)
+	} else {
+		for _, node := range q.mapnode.GetAllNodes() {
+			nodes = append(nodes, node)
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].ID < nodes[j].ID
+		})
+	}
+
+	if namespace == "" {
+		return nodes
+	}
+
+	matches := []mapnode.Node{}
+	for _, node := range nodes {
+		if node.Namespace == namespace {
+			matches = append(matches, node)
+		}
+	}
+	return matches
+}
+
+func (q *meshql) neighbors(id string, direction string, depth int) []mapnode.Reach {
+	if depth <= 0 {
+		depth = 1
+	}
+	depth = q.hops(depth)
+
+	switch direction {
+	case directionOut:
+		return q.mapnode.Downstream(id, depth)
+	case directionIn:
+		return q.mapnode.Upstream(id, depth)
+	}
+
+	// keep the nearest of both directions
+	reaches := q.mapnode.Downstream(id, depth)
+	indexes := map[string]int{}
+	for i, r := range reaches {
+		indexes[r.ID] = i
+	}
+	for _, r := range q.mapnode.Upstream(id, depth) {
+		i, ok := indexes[r.ID]
+		if !ok {
+			reaches = append(reaches, r)
+			continue
+		}
+		if r.Depth < reaches[i].Depth {
+			reaches[i].Depth = r.Depth
+		}
+	}
+	sort.SliceStable(reaches, func(i, j int) bool {
+		if reaches[i].Depth != reaches[j].Depth {
+			return reaches[i].Depth < reaches[j].Depth
+		}
+		return reaches[i].ID < reaches[j].ID
+	})
+
+	return reaches
+}
+
+func groupByPort(outbounds mapnode.Outbounds) []portGroup {
+	groups := []portGroup{}
+	indexes := map[string]int{}
+	for _, out := range outbounds {
+		i, ok := indexes[out.Port]
+		if !ok {
+			i = len(groups)
+			indexes[out.Port] = i
+			groups = append(groups, portGroup{Port: out.Port})
+		}
+		groups[i].Outbounds = append(groups[i].Outbounds, out)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Port < groups[j].Port
+	})
+	return groups
+}
+
+// nodeOf get the node of a resolver source, which is a Node or *Node
+func nodeOf(source interface{}) mapnode.Node {
+	switch node := source.(type) {
+	case *mapnode.Node:
+		return *node
+	case mapnode.Node:
+		return node
+	}
+	return mapnode.Node{}
+}
+
+func stringField(get func(source interface{}) string) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.String,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source), nil
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package meshrpc
+
+import (
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshrpc/meshpb"
+)
+
+func toProtoNode(node mapnode.Node) *meshpb.Node {
+	n := &meshpb.Node{
+		Id:        node.ID,
+		Name:      node.Name,
+		Namespace: node.Namespace,
+		Cluster:   node.Cluster,
+		Outbounds: make([]*meshpb.Outbound, len(node.Outbounds)),
+		Inbounds:  make([]*meshpb.Inbound, len(node.Inbounds)),
+	}
+	for i, out := range node.Outbounds {
+		n.Outbounds[i] = &meshpb.Outbound{
+			Id:        out.ID,
+			Name:      out.Name,
+			Namespace: out.Namespace,
+			Cluster:   out.Cluster,
+			Port:      out.Port,
+		}
+	}
+	for i, in := range node.Inbounds {
+		n.Inbounds[i] = &meshpb.Inbound{
+			Id:        in.ID,
+			Name:      in.Name,
+			Namespace: in.Namespace,
+			Cluster:   in.Cluster,
+		}
+	}
+	return n
+}
+
+func toProtoEventType(eventType string) meshpb.MeshEvent_Type {
+	switch eventType {
+	case mapnode.NodeAdded:
+		return meshpb.MeshEvent_ADDED
+	case mapnode.NodeRemoved:
+		return meshpb.MeshEvent_REMOVED
+	case mapnode.NodeChanged:
+		return meshpb.MeshEvent_CHANGED
+	}
+	return meshpb.MeshEvent_TYPE_UNSPECIFIED
+}
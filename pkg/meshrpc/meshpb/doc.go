@@ -0,0 +1,10 @@
+// Package meshpb is the generated gRPC client and server of the Mesh service.
+//
+// Regenerating it requires protoc 23.4, plugins being built at their pinned
+// versions into ./bin: protoc-gen-go from go.mod, protoc-gen-go-grpc v1.3.0.
+package meshpb
+
+//go:generate sh -c "protoc --version | grep -qx 'libprotoc 23.4' || { echo 'protoc 23.4 is required' >&2; exit 1; }"
+//go:generate go build -o bin/protoc-gen-go google.golang.org/protobuf/cmd/protoc-gen-go
+//go:generate sh -c "GOBIN=$DOLLAR(pwd)/bin go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.3.0"
+//go:generate protoc --plugin=protoc-gen-go=bin/protoc-gen-go --plugin=protoc-gen-go-grpc=bin/protoc-gen-go-grpc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative mesh.proto
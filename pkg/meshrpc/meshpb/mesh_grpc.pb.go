@@ -0,0 +1,217 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.23.4
+// source: mesh.proto
+
+package meshpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Mesh_GetNode_FullMethodName   = "/telescope.mesh.v1.Mesh/GetNode"
+	Mesh_ListNodes_FullMethodName = "/telescope.mesh.v1.Mesh/ListNodes"
+	Mesh_WatchMesh_FullMethodName = "/telescope.mesh.v1.Mesh/WatchMesh"
+)
+
+// MeshClient is the client API for Mesh service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MeshClient interface {
+	// GetNode get a node by id, or by name and namespace.
+	GetNode(ctx context.Context, in *GetNodeRequest, opts ...grpc.CallOption) (*GetNodeResponse, error)
+	// ListNodes list nodes sorted by id, optionally filtered.
+	ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesResponse, error)
+	// WatchMesh stream node changes whenever the graph is updated.
+	WatchMesh(ctx context.Context, in *WatchMeshRequest, opts ...grpc.CallOption) (Mesh_WatchMeshClient, error)
+}
+
+type meshClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMeshClient(cc grpc.ClientConnInterface) MeshClient {
+	return &meshClient{cc}
+}
+
+func (c *meshClient) GetNode(ctx context.Context, in *GetNodeRequest, opts ...grpc.CallOption) (*GetNodeResponse, error) {
+	out := new(GetNodeResponse)
+	err := c.cc.Invoke(ctx, Mesh_GetNode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meshClient) ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesResponse, error) {
+	out := new(ListNodesResponse)
+	err := c.cc.Invoke(ctx, Mesh_ListNodes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meshClient) WatchMesh(ctx context.Context, in *WatchMeshRequest, opts ...grpc.CallOption) (Mesh_WatchMeshClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Mesh_ServiceDesc.Streams[0], Mesh_WatchMesh_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &meshWatchMeshClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Mesh_WatchMeshClient interface {
+	Recv() (*MeshEvent, error)
+	grpc.ClientStream
+}
+
+type meshWatchMeshClient struct {
+	grpc.ClientStream
+}
+
+func (x *meshWatchMeshClient) Recv() (*MeshEvent, error) {
+	m := new(MeshEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MeshServer is the server API for Mesh service.
+// All implementations must embed UnimplementedMeshServer
+// for forward compatibility
+type MeshServer interface {
+	// GetNode get a node by id, or by name and namespace.
+	GetNode(context.Context, *GetNodeRequest) (*GetNodeResponse, error)
+	// ListNodes list nodes sorted by id, optionally filtered.
+	ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error)
+	// WatchMesh stream node changes whenever the graph is updated.
+	WatchMesh(*WatchMeshRequest, Mesh_WatchMeshServer) error
+	mustEmbedUnimplementedMeshServer()
+}
+
+// UnimplementedMeshServer must be embedded to have forward compatible implementations.
+type UnimplementedMeshServer struct {
+}
+
+func (UnimplementedMeshServer) GetNode(context.Context, *GetNodeRequest) (*GetNodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNode not implemented")
+}
+func (UnimplementedMeshServer) ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNodes not implemented")
+}
+func (UnimplementedMeshServer) WatchMesh(*WatchMeshRequest, Mesh_WatchMeshServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchMesh not implemented")
+}
+func (UnimplementedMeshServer) mustEmbedUnimplementedMeshServer() {}
+
+// UnsafeMeshServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MeshServer will
+// result in compilation errors.
+type UnsafeMeshServer interface {
+	mustEmbedUnimplementedMeshServer()
+}
+
+func RegisterMeshServer(s grpc.ServiceRegistrar, srv MeshServer) {
+	s.RegisterService(&Mesh_ServiceDesc, srv)
+}
+
+func _Mesh_GetNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeshServer).GetNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mesh_GetNode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeshServer).GetNode(ctx, req.(*GetNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mesh_ListNodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeshServer).ListNodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mesh_ListNodes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeshServer).ListNodes(ctx, req.(*ListNodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mesh_WatchMesh_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMeshRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MeshServer).WatchMesh(m, &meshWatchMeshServer{stream})
+}
+
+type Mesh_WatchMeshServer interface {
+	Send(*MeshEvent) error
+	grpc.ServerStream
+}
+
+type meshWatchMeshServer struct {
+	grpc.ServerStream
+}
+
+func (x *meshWatchMeshServer) Send(m *MeshEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Mesh_ServiceDesc is the grpc.ServiceDesc for Mesh service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Mesh_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "telescope.mesh.v1.Mesh",
+	HandlerType: (*MeshServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetNode",
+			Handler:    _Mesh_GetNode_Handler,
+		},
+		{
+			MethodName: "ListNodes",
+			Handler:    _Mesh_ListNodes_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchMesh",
+			Handler:       _Mesh_WatchMesh_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "mesh.proto",
+}
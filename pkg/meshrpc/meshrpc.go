@@ -0,0 +1,226 @@
+// Package meshrpc serve the mapnode graph over gRPC,
+// see meshpb for the service definition and generated client.
+package meshrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshrpc/meshpb"
+	"github.com/danztran/telescope/pkg/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var defaultLogger = utils.MustGetLogger("meshrpc")
+
+type Deps struct {
+	Log     *zap.SugaredLogger
+	Mapnode mapnode.Mapnode
+	Config  Config
+}
+
+type Config struct {
+	Port            string `mapstructure:"port"`
+	GracefulSeconds int    `mapstructure:"graceful_seconds"`
+}
+
+type Server interface {
+	Run(ctx context.Context) error
+	Serve(listener net.Listener) error
+	Stop()
+}
+
+type server struct {
+	meshpb.UnimplementedMeshServer
+
+	config  Config
+	log     *zap.SugaredLogger
+	mapnode mapnode.Mapnode
+	grpc    *grpc.Server
+}
+
+func MustNew(deps Deps) Server {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (Server, error) {
+	if deps.Mapnode == nil {
+		return nil, fmt.Errorf("mapnode is required")
+	}
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+
+	s := &server{
+		config:  deps.Config,
+		log:     deps.Log,
+		mapnode: deps.Mapnode,
+		grpc:    grpc.NewServer(),
+	}
+	meshpb.RegisterMeshServer(s.grpc, s)
+
+	return s, nil
+}
+
+// Run listen on the configured port until the context is done
+func (s *server) Run(ctx context.Context) error {
+	if s.config.Port == "" {
+		s.log.Info("disabled grpc server")
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", s.config.Port))
+	if err != nil {
+		return fmt.Errorf("error listen grpc / %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.Stop()
+	}()
+
+	s.log.Infof("grpc server started on %s", listener.Addr())
+	return s.Serve(listener)
+}
+
+// Serve serve on a listener, in-process listeners included
+func (s *server) Serve(listener net.Listener) error {
+	return s.grpc.Serve(listener)
+}
+
+// Stop stop gracefully, then forcibly after graceful seconds
+func (s *server) Stop() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.grpc.GracefulStop()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Duration(s.config.GracefulSeconds) * time.Second):
+		s.grpc.Stop()
+	}
+}
+
+func (s *server) GetNode(ctx context.Context, req *meshpb.GetNodeRequest) (*meshpb.GetNodeResponse, error) {
+	id := req.GetId()
+	if id == "" {
+		if req.GetName() == "" || req.GetNamespace() == "" {
+			return nil, status.Error(codes.InvalidArgument, "id, or name and namespace are required")
+		}
+		id = mapnode.NodeID(req.GetCluster(), req.GetNamespace(), req.GetName())
+	}
+
+	node := s.mapnode.GetNode(id)
+	if node == nil {
+		return nil, status.Errorf(codes.NotFound, "not found any node with id: %s", id)
+	}
+
+	resp := &meshpb.GetNodeResponse{
+		Node:        toProtoNode(*node),
+		LastUpdated: timestamppb.New(s.mapnode.GetLastUpdated()),
+	}
+
+	return resp, nil
+}
+
+func (s *server) ListNodes(ctx context.Context, req *meshpb.ListNodesRequest) (*meshpb.ListNodesResponse, error) {
+	var nodes []mapnode.Node
+	if req.GetName() != "" {
+		nodes = s.mapnode.GetNodesByName(req.GetName())
+	} else {
+		for _, node := range s.mapnode.GetAllNodes() {
+			nodes = append(nodes, node)
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].ID < nodes[j].ID
+		})
+	}
+
+	resp := &meshpb.ListNodesResponse{
+		Nodes:       []*meshpb.Node{},
+		LastUpdated: timestamppb.New(s.mapnode.GetLastUpdated()),
+	}
+	for _, node := range nodes {
+		if req.GetNamespace() != "" && node.Namespace != req.GetNamespace() {
+			continue
+		}
+		resp.Nodes = append(resp.Nodes, toProtoNode(node))
+	}
+
+	return resp, nil
+}
+
+func (s *server) WatchMesh(req *meshpb.WatchMeshRequest, stream meshpb.Mesh_WatchMeshServer) error {
+	// subscribe before reading current nodes, so that no update is missed
+	diffs, unsubscribe := s.mapnode.Subscribe()
+	defer unsubscribe()
+
+	match := func(node mapnode.Node) bool {
+		return req.GetNamespace() == "" || node.Namespace == req.GetNamespace()
+	}
+
+	if req.GetSendInitial() {
+		nodes := s.mapnode.GetAllNodes()
+		ids := make([]string, 0, len(nodes))
+		for id := range nodes {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		now := timestamppb.Now()
+		for _, id := range ids {
+			if !match(nodes[id]) {
+				continue
+			}
+			err := stream.Send(&meshpb.MeshEvent{
+				Type: meshpb.MeshEvent_ADDED,
+				Node: toProtoNode(nodes[id]),
+				Time: now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case diff, ok := <-diffs:
+			if !ok {
+				return status.Error(codes.Unavailable, "mesh updates stopped")
+			}
+			ts := timestamppb.New(diff.Time)
+			for _, event := range diff.Nodes {
+				if !match(event.Node) {
+					continue
+				}
+				err := stream.Send(&meshpb.MeshEvent{
+					Type: toProtoEventType(event.Type),
+					Node: toProtoNode(event.Node),
+					Time: ts,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package meshrpc_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshrpc"
+	"github.com/danztran/telescope/pkg/meshrpc/meshpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// connections is a MetricsClient returning settable connections
+type connections struct {
+	mx    sync.Mutex
+	conns []mapnode.Connection
+}
+
+func (c *connections) set(conns ...mapnode.Connection) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.conns = conns
+}
+
+func (c *connections) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.conns, nil
+}
+
+func conn(src string, dest string, port string) mapnode.Connection {
+	return mapnode.Connection{
+		Source:               src,
+		SourceNamespace:      "default",
+		Destination:          dest,
+		DestinationNamespace: "default",
+		DestinationPort:      port,
+	}
+}
+
+// newClient serve a mapnode over an in-process listener
+func newClient(t *testing.T, metrics *connections) (meshpb.MeshClient, mapnode.Mapnode) {
+	t.Helper()
+
+	m, err := mapnode.New(mapnode.Deps{MetricsClient: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := meshrpc.New(meshrpc.Deps{Mapnode: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = s.Serve(listener)
+	}()
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+
+	return meshpb.NewMeshClient(cc), m
+}
+
+func TestGetNode(t *testing.T) {
+	metrics := &connections{}
+	metrics.set(conn("api", "db", "5432"))
+	client, _ := newClient(t, metrics)
+	ctx := context.Background()
+
+	resp, err := client.GetNode(ctx, &meshpb.GetNodeRequest{Name: "api", Namespace: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := resp.GetNode()
+	if node.GetId() != "default/api" {
+		t.Errorf("got node %s, want default/api", node.GetId())
+	}
+	if len(node.GetOutbounds()) != 1 || node.GetOutbounds()[0].GetId() != "default/db" || node.GetOutbounds()[0].GetPort() != "5432" {
+		t.Errorf("got outbounds %v, want default/db:5432", node.GetOutbounds())
+	}
+
+	resp, err = client.GetNode(ctx, &meshpb.GetNodeRequest{Id: "default/db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inbounds := resp.GetNode().GetInbounds(); len(inbounds) != 1 || inbounds[0].GetId() != "default/api" {
+		t.Errorf("got inbounds %v, want default/api", inbounds)
+	}
+
+	_, err = client.GetNode(ctx, &meshpb.GetNodeRequest{Id: "default/missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+
+	_, err = client.GetNode(ctx, &meshpb.GetNodeRequest{Name: "api"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v, want InvalidArgument", err)
+	}
+}
+
+func TestListNodes(t *testing.T) {
+	metrics := &connections{}
+	metrics.set(conn("api", "db", "5432"), conn("web", "api", "80"))
+	client, _ := newClient(t, metrics)
+
+	resp, err := client.ListNodes(context.Background(), &meshpb.ListNodesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"default/api", "default/db", "default/web"}
+	nodes := resp.GetNodes()
+	if len(nodes) != len(want) {
+		t.Fatalf("got %d nodes, want %d", len(nodes), len(want))
+	}
+	for i, node := range nodes {
+		if node.GetId() != want[i] {
+			t.Errorf("got node %d %s, want %s", i, node.GetId(), want[i])
+		}
+	}
+	if resp.GetLastUpdated().AsTime().IsZero() {
+		t.Error("got no last updated time")
+	}
+}
+
+func TestWatchMesh(t *testing.T) {
+	metrics := &connections{}
+	metrics.set(conn("api", "db", "5432"))
+	client, m := newClient(t, metrics)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchMesh(ctx, &meshpb.WatchMeshRequest{SendInitial: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(typ meshpb.MeshEvent_Type, id string) {
+		t.Helper()
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.GetType() != typ || event.GetNode().GetId() != id {
+			t.Errorf("got %s %s, want %s %s", event.GetType(), event.GetNode().GetId(), typ, id)
+		}
+	}
+
+	expect(meshpb.MeshEvent_ADDED, "default/api")
+	expect(meshpb.MeshEvent_ADDED, "default/db")
+
+	// api now calls cache instead of db
+	metrics.set(conn("api", "cache", "6379"))
+	if err := m.UpdateData(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(meshpb.MeshEvent_CHANGED, "default/api")
+	expect(meshpb.MeshEvent_ADDED, "default/cache")
+	expect(meshpb.MeshEvent_REMOVED, "default/db")
+}
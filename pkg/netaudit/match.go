@@ -0,0 +1,111 @@
+package netaudit
+
+import (
+	"strconv"
+
+	core "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// pod is the pod template of a workload
+type pod struct {
+	namespace string
+	labels    map[string]string
+	ports     []core.ContainerPort
+	// known is false if the workload is not found in the cluster
+	known bool
+}
+
+// matchSelector check labels against a label selector, nil selecting nothing
+func matchSelector(selector *meta.LabelSelector, set map[string]string) bool {
+	if selector == nil {
+		return false
+	}
+	s, err := meta.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(set))
+}
+
+// hasType check whether a policy apply to a direction
+func hasType(policy networking.NetworkPolicy, direction string) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		// defaulted by the api server: ingress, and egress if it has egress rules
+		return direction == DirectionIngress || len(policy.Spec.Egress) > 0
+	}
+	for _, t := range policy.Spec.PolicyTypes {
+		if string(t) == direction {
+			return true
+		}
+	}
+	return false
+}
+
+// selects check whether a policy isolate a pod in a direction
+func selects(policy networking.NetworkPolicy, p *pod, direction string) bool {
+	return policy.Namespace == p.namespace &&
+		hasType(policy, direction) &&
+		matchSelector(&policy.Spec.PodSelector, p.labels)
+}
+
+// matchPeers check whether a peer list (any peer if empty) select a pod.
+// IP blocks are never matched, pod ips being unknown.
+func matchPeers(peers []networking.NetworkPolicyPeer, policyNamespace string, other *pod, namespaceLabels map[string]string) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			continue
+		}
+		if peer.NamespaceSelector != nil {
+			if !matchSelector(peer.NamespaceSelector, namespaceLabels) {
+				continue
+			}
+		} else if other.namespace != policyNamespace {
+			continue
+		}
+		if peer.PodSelector != nil && !matchSelector(peer.PodSelector, other.labels) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// matchPorts check whether a port list (any port if empty) include a TCP port,
+// named ports being resolved on the destination pod
+func matchPorts(ports []networking.NetworkPolicyPort, port string, dest *pod) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	number, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, p := range ports {
+		if p.Protocol != nil && *p.Protocol != core.ProtocolTCP {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		if p.Port.Type == intstr.Int {
+			if p.Port.IntValue() == number {
+				return true
+			}
+			continue
+		}
+		for _, cp := range dest.ports {
+			if cp.Name == p.Port.StrVal && int(cp.ContainerPort) == number &&
+				(cp.Protocol == "" || cp.Protocol == core.ProtocolTCP) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,391 @@
+// Package netaudit audit NetworkPolicies of the cluster against connections
+// observed by the collector: which ones they allow or deny, which rules
+// are unused, and what default-deny would block.
+package netaudit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/kube"
+	"github.com/danztran/telescope/pkg/promscope"
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	networking "k8s.io/api/networking/v1"
+)
+
+var defaultLogger = utils.MustGetLogger("netaudit")
+
+// directions of policy rules
+const (
+	DirectionIngress string = "Ingress"
+	DirectionEgress  string = "Egress"
+)
+
+// decisions of policies for an edge in a direction
+const (
+	// DecisionAllowed is allowed by a policy rule
+	DecisionAllowed string = "allowed"
+	// DecisionDenied is the pod isolated by policies, no rule allowing the edge
+	DecisionDenied string = "denied"
+	// DecisionUnrestricted is the pod not isolated by any policy
+	DecisionUnrestricted string = "unrestricted"
+	// DecisionUnknown is a workload not found in the cluster
+	DecisionUnknown string = "unknown"
+)
+
+type Deps struct {
+	Log       *zap.SugaredLogger
+	Kube      kube.Kube
+	Collector collector.Collector
+	// Metrics is the namespace & subsystem of exposed metrics
+	Metrics collector.Metrics
+	Config  Config
+}
+
+type Config struct {
+	EvaluateInterval time.Duration `mapstructure:"evaluate_interval"`
+}
+
+// Verdict is the decision of current policies on an observed edge
+type Verdict struct {
+	Source               string `json:"src"`
+	SourceNamespace      string `json:"src_ns"`
+	Destination          string `json:"dest"`
+	DestinationNamespace string `json:"dest_ns"`
+	Port                 string `json:"dest_port"`
+	Allowed              bool   `json:"allowed"`
+	Ingress              string `json:"ingress"`
+	Egress               string `json:"egress"`
+	// Rules allowing the edge
+	Rules []Rule `json:"rules"`
+	// DefaultDenyBlocked are directions in which default-deny would block the edge
+	DefaultDenyBlocked []string `json:"default_deny_blocked"`
+}
+
+// Rule is an ingress or egress rule of a policy, by index
+type Rule struct {
+	Namespace string `json:"namespace"`
+	Policy    string `json:"policy"`
+	Direction string `json:"direction"`
+	Index     int    `json:"index"`
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%s/%s %s[%d]", r.Namespace, r.Policy, r.Direction, r.Index)
+}
+
+// Report is the result of an audit
+type Report struct {
+	Edges       []Verdict `json:"edges"`
+	UnusedRules []Rule    `json:"unused_rules"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+type Auditor interface {
+	Audit(ctx context.Context) Report
+	GetReport() Report
+	Run(ctx context.Context)
+}
+
+type auditor struct {
+	config        Config
+	log           *zap.SugaredLogger
+	kube          kube.Kube
+	collector     collector.Collector
+	deniedMetric  *utils.GaugeSeries
+	blockedMetric *utils.GaugeSeries
+	unusedMetric  *utils.GaugeSeries
+
+	mx     sync.RWMutex
+	report Report
+}
+
+func MustNew(deps Deps) Auditor {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (Auditor, error) {
+	if deps.Kube == nil {
+		return nil, fmt.Errorf("kube is required")
+	}
+	if deps.Collector == nil {
+		return nil, fmt.Errorf("collector is required")
+	}
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	config := deps.Config
+	if config.EvaluateInterval <= 0 {
+		config.EvaluateInterval = 5 * time.Minute
+	}
+
+	edgeLabels := []string{"src", "src_ns", "dest", "dest_ns", "dest_port", "direction"}
+
+	deniedMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      promscope.NetpolDeniedMetric,
+		Subsystem: deps.Metrics.Subsystem,
+		Namespace: deps.Metrics.Namespace,
+		Help:      "Observed connections denied by current network policies.",
+	}, edgeLabels)
+
+	blockedMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      promscope.NetpolDefaultDenyMetric,
+		Subsystem: deps.Metrics.Subsystem,
+		Namespace: deps.Metrics.Namespace,
+		Help:      "Observed connections that default-deny network policies would block.",
+	}, edgeLabels)
+
+	unusedMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      promscope.NetpolUnusedRulesMetric,
+		Subsystem: deps.Metrics.Subsystem,
+		Namespace: deps.Metrics.Namespace,
+		Help:      "Network policy rules allowing no observed connection.",
+	}, []string{"namespace", "policy", "direction"})
+
+	for _, metric := range []*prometheus.GaugeVec{deniedMetric, blockedMetric, unusedMetric} {
+		if err := prometheus.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	a := &auditor{
+		config:        config,
+		log:           deps.Log,
+		kube:          deps.Kube,
+		collector:     deps.Collector,
+		deniedMetric:  utils.NewGaugeSeries(deniedMetric),
+		blockedMetric: utils.NewGaugeSeries(blockedMetric),
+		unusedMetric:  utils.NewGaugeSeries(unusedMetric),
+		report:        Report{Edges: []Verdict{}, UnusedRules: []Rule{}},
+	}
+
+	return a, nil
+}
+
+// Run audit policies in interval until the context is done
+func (a *auditor) Run(ctx context.Context) {
+	utils.RunStateless(ctx, a.config.EvaluateInterval, func() {
+		a.Audit(ctx)
+	})
+}
+
+// GetReport get the last audit report
+func (a *auditor) GetReport() Report {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+	return a.report
+}
+
+// Audit decide current policies on the collector edges,
+// update metrics and keep the report
+func (a *auditor) Audit(ctx context.Context) Report {
+	policies := a.kube.GetNetworkPolicies("")
+	pods := map[string]*pod{}
+	namespaces := map[string]map[string]string{}
+	used := map[Rule]bool{}
+
+	report := Report{
+		Edges:       []Verdict{},
+		UnusedRules: []Rule{},
+		EvaluatedAt: time.Now(),
+	}
+
+	seen := map[string]bool{}
+	for _, e := range a.collector.GetEdges() {
+		v := Verdict{
+			Source:               e.Source,
+			SourceNamespace:      e.SourceNamespace,
+			Destination:          e.Destination,
+			DestinationNamespace: e.DestinationNamespace,
+			Port:                 e.DestinationPort,
+			Rules:                []Rule{},
+			DefaultDenyBlocked:   []string{},
+		}
+		if seen[v.key()] {
+			// same edge in another topology
+			continue
+		}
+		seen[v.key()] = true
+
+		src := a.pod(pods, e.SourceNamespace, e.Source)
+		dest := a.pod(pods, e.DestinationNamespace, e.Destination)
+
+		var rules []Rule
+		v.Ingress, rules = decide(policies, DirectionIngress, dest, src, a.namespaceLabels(namespaces, src.namespace), v.Port, dest)
+		v.Rules = append(v.Rules, rules...)
+		v.Egress, rules = decide(policies, DirectionEgress, src, dest, a.namespaceLabels(namespaces, dest.namespace), v.Port, dest)
+		v.Rules = append(v.Rules, rules...)
+		for _, r := range v.Rules {
+			used[r] = true
+		}
+
+		v.Allowed = permits(v.Ingress) && permits(v.Egress)
+		if v.Ingress == DecisionUnrestricted {
+			v.DefaultDenyBlocked = append(v.DefaultDenyBlocked, DirectionIngress)
+		}
+		if v.Egress == DecisionUnrestricted {
+			v.DefaultDenyBlocked = append(v.DefaultDenyBlocked, DirectionEgress)
+		}
+
+		report.Edges = append(report.Edges, v)
+	}
+	sort.Slice(report.Edges, func(i, j int) bool {
+		return report.Edges[i].key() < report.Edges[j].key()
+	})
+
+	for _, policy := range policies {
+		for i := range policy.Spec.Ingress {
+			r := Rule{Namespace: policy.Namespace, Policy: policy.Name, Direction: DirectionIngress, Index: i}
+			if !used[r] {
+				report.UnusedRules = append(report.UnusedRules, r)
+			}
+		}
+		for i := range policy.Spec.Egress {
+			r := Rule{Namespace: policy.Namespace, Policy: policy.Name, Direction: DirectionEgress, Index: i}
+			if !used[r] {
+				report.UnusedRules = append(report.UnusedRules, r)
+			}
+		}
+	}
+	sort.Slice(report.UnusedRules, func(i, j int) bool {
+		return report.UnusedRules[i].String() < report.UnusedRules[j].String()
+	})
+
+	a.expose(report)
+
+	a.log.Debugf("audited edges: %d, unused rules: %d", len(report.Edges), len(report.UnusedRules))
+
+	a.mx.Lock()
+	a.report = report
+	a.mx.Unlock()
+
+	return report
+}
+
+// expose replace the metrics series by the report ones
+func (a *auditor) expose(report Report) {
+	for _, v := range report.Edges {
+		labels := func(direction string) []string {
+			return []string{v.Source, v.SourceNamespace, v.Destination, v.DestinationNamespace, v.Port, direction}
+		}
+		if v.Ingress == DecisionDenied {
+			a.deniedMetric.Set(1, labels(DirectionIngress)...)
+		}
+		if v.Egress == DecisionDenied {
+			a.deniedMetric.Set(1, labels(DirectionEgress)...)
+		}
+		for _, direction := range v.DefaultDenyBlocked {
+			a.blockedMetric.Set(1, labels(direction)...)
+		}
+	}
+
+	for _, r := range report.UnusedRules {
+		a.unusedMetric.Add(1, r.Namespace, r.Policy, r.Direction)
+	}
+
+	a.deniedMetric.Commit()
+	a.blockedMetric.Commit()
+	a.unusedMetric.Commit()
+}
+
+// pod resolve the pod template of a workload, cached in pods
+func (a *auditor) pod(pods map[string]*pod, namespace string, name string) *pod {
+	key := namespace + "/" + name
+	if p, ok := pods[key]; ok {
+		return p
+	}
+
+	p := &pod{namespace: namespace}
+	if object := a.kube.GetWorkload(namespace, name); object != nil {
+		if template := kube.PodTemplate(object); template != nil {
+			p.known = true
+			p.labels = template.Labels
+			for _, c := range template.Spec.Containers {
+				p.ports = append(p.ports, c.Ports...)
+			}
+		}
+	}
+	pods[key] = p
+
+	return p
+}
+
+// namespaceLabels get labels of a namespace, cached in namespaces
+func (a *auditor) namespaceLabels(namespaces map[string]map[string]string, name string) map[string]string {
+	if set, ok := namespaces[name]; ok {
+		return set
+	}
+
+	set := map[string]string{}
+	if ns := a.kube.GetNamespace(name); ns != nil {
+		for k, v := range ns.Labels {
+			set[k] = v
+		}
+	}
+	// set by kubernetes 1.21+, missing on older clusters
+	set[kube.NamespaceNameLabel] = name
+	namespaces[name] = set
+
+	return set
+}
+
+// decide policies isolating a subject pod in a direction, for its peer on a
+// destination port, and return the rules allowing it
+func decide(policies []networking.NetworkPolicy, direction string, subject *pod, peer *pod, peerNamespaceLabels map[string]string, port string, dest *pod) (string, []Rule) {
+	if !subject.known {
+		return DecisionUnknown, nil
+	}
+
+	isolated := false
+	rules := []Rule{}
+	for _, policy := range policies {
+		if !selects(policy, subject, direction) {
+			continue
+		}
+		isolated = true
+
+		if direction == DirectionIngress {
+			for i, rule := range policy.Spec.Ingress {
+				if matchPeers(rule.From, policy.Namespace, peer, peerNamespaceLabels) && matchPorts(rule.Ports, port, dest) {
+					rules = append(rules, Rule{Namespace: policy.Namespace, Policy: policy.Name, Direction: direction, Index: i})
+				}
+			}
+			continue
+		}
+		for i, rule := range policy.Spec.Egress {
+			if matchPeers(rule.To, policy.Namespace, peer, peerNamespaceLabels) && matchPorts(rule.Ports, port, dest) {
+				rules = append(rules, Rule{Namespace: policy.Namespace, Policy: policy.Name, Direction: direction, Index: i})
+			}
+		}
+	}
+
+	switch {
+	case !isolated:
+		return DecisionUnrestricted, rules
+	case len(rules) > 0:
+		return DecisionAllowed, rules
+	case !peer.known:
+		// peer labels are unknown, rules may select it
+		return DecisionUnknown, rules
+	default:
+		return DecisionDenied, rules
+	}
+}
+
+func permits(decision string) bool {
+	return decision == DecisionAllowed || decision == DecisionUnrestricted
+}
+
+func (v Verdict) key() string {
+	return fmt.Sprintf("%s/%s -> %s/%s:%s", v.SourceNamespace, v.Source, v.DestinationNamespace, v.Destination, v.Port)
+}
@@ -0,0 +1,202 @@
+package netpol
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ContentType of rendered manifests
+const ContentType string = "application/yaml"
+
+// actions of dry run diffs
+const (
+	ActionCreate    string = "create"
+	ActionUpdate    string = "update"
+	ActionUnchanged string = "unchanged"
+	// ActionDelete is a generated policy in the cluster not generated anymore
+	ActionDelete string = "delete"
+	// ActionUnmanaged is a policy in the cluster not generated by telescope
+	ActionUnmanaged string = "unmanaged"
+)
+
+// Diff compare a generated policy with the policy in the cluster
+type Diff struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	// Text is a line diff of the manifests, if created, updated or deleted
+	Text string `json:"diff,omitempty"`
+}
+
+// Render write policies as a multi-document YAML manifest
+func Render(w io.Writer, policies []networking.NetworkPolicy) error {
+	for i, policy := range policies {
+		data, err := marshal(policy)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compare policies in the cluster with generated ones
+func compare(current []networking.NetworkPolicy, desired []networking.NetworkPolicy) ([]Diff, error) {
+	existing := make(map[string]networking.NetworkPolicy, len(current))
+	for _, policy := range current {
+		existing[policy.Namespace+"/"+policy.Name] = policy
+	}
+
+	diffs := []Diff{}
+	generated := map[string]bool{}
+	for _, policy := range desired {
+		key := policy.Namespace + "/" + policy.Name
+		generated[key] = true
+		diff := Diff{Namespace: policy.Namespace, Name: policy.Name}
+
+		old, ok := existing[key]
+		switch {
+		case !ok:
+			diff.Action = ActionCreate
+		case equality.Semantic.DeepEqual(old.Spec, policy.Spec):
+			diff.Action = ActionUnchanged
+		default:
+			diff.Action = ActionUpdate
+		}
+
+		if diff.Action != ActionUnchanged {
+			var before *networking.NetworkPolicy
+			if ok {
+				before = &old
+			}
+			text, err := diffText(before, &policy)
+			if err != nil {
+				return nil, err
+			}
+			diff.Text = text
+		}
+		diffs = append(diffs, diff)
+	}
+
+	for key, policy := range existing {
+		if generated[key] {
+			continue
+		}
+		diff := Diff{Namespace: policy.Namespace, Name: policy.Name, Action: ActionUnmanaged}
+		if policy.Labels[ManagedByLabel] == ManagedBy {
+			diff.Action = ActionDelete
+			text, err := diffText(&policy, nil)
+			if err != nil {
+				return nil, err
+			}
+			diff.Text = text
+		}
+		diffs = append(diffs, diff)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Namespace != diffs[j].Namespace {
+			return diffs[i].Namespace < diffs[j].Namespace
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+
+	return diffs, nil
+}
+
+// marshal a policy manifest, without server populated fields
+func marshal(policy networking.NetworkPolicy) ([]byte, error) {
+	manifest := networking.NetworkPolicy{
+		TypeMeta: meta.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: meta.ObjectMeta{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			Labels:    policy.Labels,
+		},
+		Spec: policy.Spec,
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error marshal policy %s/%s / %w", policy.Namespace, policy.Name, err)
+	}
+
+	return data, nil
+}
+
+// diffText diff manifests line by line, nil being an absent policy
+func diffText(before *networking.NetworkPolicy, after *networking.NetworkPolicy) (string, error) {
+	var a, b []string
+	if before != nil {
+		data, err := marshal(*before)
+		if err != nil {
+			return "", err
+		}
+		a = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	if after != nil {
+		data, err := marshal(*after)
+		if err != nil {
+			return "", err
+		}
+		b = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+
+	return strings.Join(diffLines(a, b), "\n"), nil
+}
+
+// diffLines prefix removed lines with "-", added lines with "+"
+// and common lines with " ", by longest common subsequence
+func diffLines(a []string, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	return lines
+}
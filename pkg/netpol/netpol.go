@@ -0,0 +1,348 @@
+// Package netpol generate least-privilege NetworkPolicies
+// from connections observed in the mapnode graph.
+package netpol
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/danztran/telescope/pkg/kube"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/utils"
+	"go.uber.org/zap"
+	core "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var defaultLogger = utils.MustGetLogger("netpol")
+
+const (
+	// ManagedByLabel mark generated policies
+	ManagedByLabel string = "app.kubernetes.io/managed-by"
+	ManagedBy      string = "telescope"
+)
+
+type Deps struct {
+	Log           *zap.SugaredLogger
+	Kube          kube.Kube
+	Mapnode       mapnode.Mapnode
+	MetricsClient mapnode.MetricsClient
+	Config        Config
+}
+
+type Config struct {
+	// Window of observed connections, the mapnode graph window if not set
+	Window *time.Duration `mapstructure:"window"`
+	// Cluster is the name of the kube cluster in the graph, if multi-cluster
+	Cluster    string `mapstructure:"cluster"`
+	NamePrefix string `mapstructure:"name_prefix"`
+	// Egress also restrict egress of workloads to observed destinations
+	Egress bool `mapstructure:"egress"`
+}
+
+type Options struct {
+	Namespace string
+	// Window override the configured window if set
+	Window *time.Duration
+	// DryRun compare generated policies with policies in the cluster
+	DryRun bool
+}
+
+// Result is generated policies, with the graph nodes that could not be
+// resolved to workloads, and the comparison with the cluster if dry run.
+// Incomplete nodes have peers that policies cannot select: unresolved nodes
+// or nodes of other clusters. They get no policy, which would deny them.
+type Result struct {
+	Policies   []networking.NetworkPolicy `json:"policies"`
+	Unresolved []string                   `json:"unresolved"`
+	Incomplete []string                   `json:"incomplete"`
+	Diffs      []Diff                     `json:"diffs,omitempty"`
+}
+
+type Generator interface {
+	Generate(ctx context.Context, opt Options) (*Result, error)
+}
+
+type generator struct {
+	config        Config
+	log           *zap.SugaredLogger
+	kube          kube.Kube
+	mapnode       mapnode.Mapnode
+	metricsClient mapnode.MetricsClient
+}
+
+// edges map a node id to its peer ids, to the observed ports
+type edges map[string]map[string][]string
+
+func (e edges) add(id string, peer string, port string) {
+	if e[id] == nil {
+		e[id] = map[string][]string{}
+	}
+	e[id][peer] = append(e[id][peer], port)
+}
+
+func MustNew(deps Deps) Generator {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (Generator, error) {
+	if deps.Kube == nil {
+		return nil, fmt.Errorf("kube is required")
+	}
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	config := deps.Config
+	if config.NamePrefix == "" {
+		config.NamePrefix = "telescope-"
+	}
+
+	g := &generator{
+		config:        config,
+		log:           deps.Log,
+		kube:          deps.Kube,
+		mapnode:       deps.Mapnode,
+		metricsClient: deps.MetricsClient,
+	}
+
+	return g, nil
+}
+
+// Generate build a policy per workload of the graph, selecting its pods,
+// allowing ingress from observed sources (and egress to observed destinations)
+// on observed ports only
+func (g *generator) Generate(ctx context.Context, opt Options) (*Result, error) {
+	nodes, err := g.graph(ctx, opt.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	ingress := edges{}
+	egress := edges{}
+	// external are nodes of the cluster with peers in other clusters
+	external := map[string]bool{}
+	for _, node := range nodes {
+		local := node.Cluster == g.config.Cluster
+		for _, out := range node.Outbounds {
+			switch {
+			case local && out.Cluster == g.config.Cluster:
+				ingress.add(out.ID, node.ID, out.Port)
+				egress.add(node.ID, out.ID, out.Port)
+			case out.Cluster == g.config.Cluster:
+				external[out.ID] = true
+			case local && g.config.Egress:
+				external[node.ID] = true
+			}
+		}
+	}
+
+	result := &Result{
+		Policies:   []networking.NetworkPolicy{},
+		Unresolved: []string{},
+		Incomplete: []string{},
+	}
+
+	selectors := map[string]*meta.LabelSelector{}
+	selector := func(node mapnode.Node) *meta.LabelSelector {
+		if s, ok := selectors[node.ID]; ok {
+			return s
+		}
+		var s *meta.LabelSelector
+		if object := g.kube.GetWorkload(node.Namespace, node.Name); object != nil {
+			s = kube.PodSelector(object)
+		}
+		if s != nil && len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0 {
+			// never select all pods of a namespace
+			s = nil
+		}
+		if s == nil {
+			result.Unresolved = append(result.Unresolved, node.ID)
+		}
+		selectors[node.ID] = s
+		return s
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for id, node := range nodes {
+		if node.Cluster != g.config.Cluster {
+			continue
+		}
+		if opt.Namespace != "" && node.Namespace != opt.Namespace {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		node := nodes[id]
+		podSelector := selector(node)
+		if podSelector == nil {
+			continue
+		}
+
+		policy := networking.NetworkPolicy{
+			TypeMeta: meta.TypeMeta{
+				APIVersion: "networking.k8s.io/v1",
+				Kind:       "NetworkPolicy",
+			},
+			ObjectMeta: meta.ObjectMeta{
+				Name:      g.config.NamePrefix + node.Name,
+				Namespace: node.Namespace,
+				Labels:    map[string]string{ManagedByLabel: ManagedBy},
+			},
+			Spec: networking.NetworkPolicySpec{
+				PodSelector: *podSelector,
+				PolicyTypes: []networking.PolicyType{networking.PolicyTypeIngress},
+			},
+		}
+
+		complete := !external[id]
+		for _, peerID := range sortedKeys(ingress[id]) {
+			peer, ok := g.peer(node, nodes[peerID], selector)
+			if !ok {
+				complete = false
+				continue
+			}
+			policy.Spec.Ingress = append(policy.Spec.Ingress, networking.NetworkPolicyIngressRule{
+				From:  []networking.NetworkPolicyPeer{peer},
+				Ports: policyPorts(ingress[id][peerID]),
+			})
+		}
+
+		if g.config.Egress {
+			policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networking.PolicyTypeEgress)
+			for _, peerID := range sortedKeys(egress[id]) {
+				peer, ok := g.peer(node, nodes[peerID], selector)
+				if !ok {
+					complete = false
+					continue
+				}
+				policy.Spec.Egress = append(policy.Spec.Egress, networking.NetworkPolicyEgressRule{
+					To:    []networking.NetworkPolicyPeer{peer},
+					Ports: policyPorts(egress[id][peerID]),
+				})
+			}
+			policy.Spec.Egress = append(policy.Spec.Egress, dnsEgressRule())
+		}
+
+		if !complete {
+			result.Incomplete = append(result.Incomplete, id)
+			continue
+		}
+		result.Policies = append(result.Policies, policy)
+	}
+	sort.Strings(result.Unresolved)
+
+	if opt.DryRun {
+		current := g.kube.GetNetworkPolicies(opt.Namespace)
+		if result.Diffs, err = compare(current, result.Policies); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// graph get nodes of the mapnode graph, or of a graph updated
+// from the metrics client over the window if set
+func (g *generator) graph(ctx context.Context, window *time.Duration) (map[string]mapnode.Node, error) {
+	if window == nil {
+		window = g.config.Window
+	}
+	if window == nil {
+		if g.mapnode == nil {
+			return nil, fmt.Errorf("window is required")
+		}
+		return g.mapnode.GetAllNodes(), nil
+	}
+	if g.metricsClient == nil {
+		return nil, fmt.Errorf("metrics client is required to use a window")
+	}
+
+	m, err := mapnode.NewWithContext(ctx, mapnode.Deps{
+		Log:           g.log,
+		MetricsClient: g.metricsClient,
+		Config:        mapnode.Config{GetConnectionsSince: *window},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get connections over %s / %w", *window, err)
+	}
+
+	return m.GetAllNodes(), nil
+}
+
+// peer select pods of a peer node, in its namespace
+func (g *generator) peer(node mapnode.Node, other mapnode.Node, selector func(mapnode.Node) *meta.LabelSelector) (networking.NetworkPolicyPeer, bool) {
+	podSelector := selector(other)
+	if podSelector == nil {
+		return networking.NetworkPolicyPeer{}, false
+	}
+
+	peer := networking.NetworkPolicyPeer{PodSelector: podSelector}
+	if other.Namespace != node.Namespace {
+		peer.NamespaceSelector = &meta.LabelSelector{
+			MatchLabels: map[string]string{kube.NamespaceNameLabel: other.Namespace},
+		}
+	}
+
+	return peer, true
+}
+
+// dnsEgressRule allow name resolution by kube-dns, which is never observed
+// as a connection but is required by any restricted egress
+func dnsEgressRule() networking.NetworkPolicyEgressRule {
+	udp := core.ProtocolUDP
+	tcp := core.ProtocolTCP
+	port := intstr.FromInt(53)
+
+	return networking.NetworkPolicyEgressRule{
+		To: []networking.NetworkPolicyPeer{{
+			NamespaceSelector: &meta.LabelSelector{
+				MatchLabels: map[string]string{kube.NamespaceNameLabel: "kube-system"},
+			},
+			PodSelector: &meta.LabelSelector{
+				MatchLabels: map[string]string{"k8s-app": "kube-dns"},
+			},
+		}},
+		Ports: []networking.NetworkPolicyPort{
+			{Protocol: &udp, Port: &port},
+			{Protocol: &tcp, Port: &port},
+		},
+	}
+}
+
+// policyPorts get the ports of observed connections, which are TCP only
+func policyPorts(ports []string) []networking.NetworkPolicyPort {
+	sort.Strings(ports)
+	protocol := core.ProtocolTCP
+	list := make([]networking.NetworkPolicyPort, 0, len(ports))
+	for i, port := range ports {
+		if i > 0 && port == ports[i-1] {
+			continue
+		}
+		p := intstr.Parse(port)
+		list = append(list, networking.NetworkPolicyPort{
+			Protocol: &protocol,
+			Port:     &p,
+		})
+	}
+	return list
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
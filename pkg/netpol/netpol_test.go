@@ -0,0 +1,45 @@
+package netpol
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+// blocking is a MetricsClient blocking until the context is done
+type blocking struct {
+	started chan struct{}
+}
+
+func (b *blocking) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
+	close(b.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestWindowedGraphBoundToTheContext(t *testing.T) {
+	metrics := &blocking{started: make(chan struct{})}
+	g := &generator{metricsClient: metrics}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	window := time.Hour
+	go func() {
+		_, err := g.graph(ctx, &window)
+		errs <- err
+	}()
+
+	<-metrics.started
+	cancel()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("got windowed graph running after its context is cancelled")
+	}
+}
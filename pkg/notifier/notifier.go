@@ -0,0 +1,321 @@
+// Package notifier notify receivers of dependencies appearing or vanishing
+// in the mapnode graph, according to configured rules.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var defaultLogger = utils.MustGetLogger("notifier")
+
+type Deps struct {
+	Log     *zap.SugaredLogger
+	Mapnode mapnode.Mapnode
+	Config  Config
+}
+
+type Config struct {
+	Rules       []Rule        `mapstructure:"rules"`
+	Receivers   []Receiver    `mapstructure:"receivers"`
+	Silences    []Silence     `mapstructure:"silences"`
+	Retry       Retry         `mapstructure:"retry"`
+	DedupWindow time.Duration `mapstructure:"dedup_window"`
+}
+
+type Retry struct {
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Backoff     time.Duration `mapstructure:"backoff"`
+}
+
+// Notification is an edge event matching a rule
+type Notification struct {
+	Rule        string    `json:"rule"`
+	Event       string    `json:"event"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Port        string    `json:"port"`
+	Time        time.Time `json:"time"`
+}
+
+func (n Notification) key() string {
+	return fmt.Sprintf("%s|%s|%s -> %s:%s", n.Rule, n.Event, n.Source, n.Destination, n.Port)
+}
+
+func (n Notification) String() string {
+	verb := "started calling"
+	if n.Event == mapnode.EdgeRemoved {
+		verb = "stopped calling"
+	}
+	return fmt.Sprintf("[%s] %s %s %s on port %s", n.Rule, n.Source, verb, n.Destination, n.Port)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, diff mapnode.Diff) []Notification
+	Run(ctx context.Context)
+}
+
+type notifier struct {
+	config    Config
+	log       *zap.SugaredLogger
+	mapnode   mapnode.Mapnode
+	rules     []*rule
+	receivers map[string]sender
+	silences  []*silence
+	metric    *prometheus.CounterVec
+
+	mx   sync.Mutex
+	sent map[string]time.Time
+}
+
+func MustNew(deps Deps) Notifier {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (Notifier, error) {
+	config := deps.Config
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	if config.Retry.MaxAttempts <= 0 {
+		config.Retry.MaxAttempts = 1
+	}
+
+	receivers := make(map[string]sender, len(config.Receivers))
+	for _, cfg := range config.Receivers {
+		if _, ok := receivers[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicated receiver: %s", cfg.Name)
+		}
+		r, err := newSender(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error create receiver %s / %w", cfg.Name, err)
+		}
+		receivers[cfg.Name] = r
+	}
+
+	rules := make([]*rule, len(config.Rules))
+	for i, cfg := range config.Rules {
+		r, err := newRule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error create rule %s / %w", cfg.Name, err)
+		}
+		for _, name := range cfg.Receivers {
+			if _, ok := receivers[name]; !ok {
+				return nil, fmt.Errorf("rule %s: unknown receiver: %s", cfg.Name, name)
+			}
+		}
+		rules[i] = r
+	}
+
+	silences := make([]*silence, len(config.Silences))
+	for i, cfg := range config.Silences {
+		s, err := newSilence(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error create silence %d / %w", i, err)
+		}
+		silences[i] = s
+	}
+
+	metric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifier_notifications_total",
+		Help: "Notifications sent to receivers, by delivery status.",
+	}, []string{"receiver", "status"})
+
+	if err := prometheus.Register(metric); err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		metric = are.ExistingCollector.(*prometheus.CounterVec)
+	}
+
+	n := &notifier{
+		config:    config,
+		log:       deps.Log,
+		mapnode:   deps.Mapnode,
+		rules:     rules,
+		receivers: receivers,
+		silences:  silences,
+		metric:    metric,
+		sent:      make(map[string]time.Time),
+	}
+
+	return n, nil
+}
+
+// Run notify diffs of mapnode updates until the context is done
+func (n *notifier) Run(ctx context.Context) {
+	if len(n.rules) == 0 {
+		n.log.Info("disabled notifier: no rules")
+		return
+	}
+
+	diffs, unsubscribe := n.mapnode.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case diff, ok := <-diffs:
+			if !ok {
+				return
+			}
+			n.Notify(ctx, diff)
+		}
+	}
+}
+
+// Notify match diff edges against rules and send the resulting notifications,
+// except silenced or duplicated ones. It returns the notifications delivered
+// to at least one receiver.
+func (n *notifier) Notify(ctx context.Context, diff mapnode.Diff) []Notification {
+	batches := map[string][]Notification{}
+	n.prune()
+
+	for _, edge := range diff.Edges {
+		for _, r := range n.rules {
+			if !r.match(edge) {
+				continue
+			}
+			notification := Notification{
+				Rule:        r.Name,
+				Event:       edge.Type,
+				Source:      edge.Source,
+				Destination: edge.Destination,
+				Port:        edge.Port,
+				Time:        diff.Time,
+			}
+			if n.silenced(notification) {
+				n.log.Debugf("silenced notification: %s", notification)
+				continue
+			}
+			for _, receiver := range r.Receivers {
+				if n.duplicated(receiver, notification) {
+					n.log.Debugf("duplicated notification to %s: %s", receiver, notification)
+					continue
+				}
+				batches[receiver] = append(batches[receiver], notification)
+			}
+		}
+	}
+
+	mx := sync.Mutex{}
+	delivered := map[string]Notification{}
+	wg := sync.WaitGroup{}
+	for name, notifications := range batches {
+		wg.Add(1)
+		go func(name string, notifications []Notification) {
+			defer wg.Done()
+			if !n.deliver(ctx, name, notifications) {
+				return
+			}
+			n.record(name, notifications)
+			mx.Lock()
+			for _, notification := range notifications {
+				delivered[notification.key()] = notification
+			}
+			mx.Unlock()
+		}(name, notifications)
+	}
+	wg.Wait()
+
+	sent := make([]Notification, 0, len(delivered))
+	for _, notification := range delivered {
+		sent = append(sent, notification)
+	}
+	sort.Slice(sent, func(i, j int) bool {
+		return sent[i].key() < sent[j].key()
+	})
+
+	return sent
+}
+
+// deliver send notifications to a receiver, retrying with exponential backoff.
+// It returns whether the notifications are sent.
+func (n *notifier) deliver(ctx context.Context, name string, notifications []Notification) bool {
+	receiver := n.receivers[name]
+	backoff := n.config.Retry.Backoff
+
+	var err error
+	for attempt := 1; attempt <= n.config.Retry.MaxAttempts; attempt++ {
+		err = receiver.send(ctx, notifications)
+		if err == nil {
+			n.metric.WithLabelValues(name, "sent").Add(float64(len(notifications)))
+			n.log.Infof("sent %d notifications to %s", len(notifications), name)
+			return true
+		}
+		if !retryable(err) || attempt == n.config.Retry.MaxAttempts {
+			break
+		}
+
+		n.log.Warnf("error send notifications to %s (attempt %d) / %s", name, attempt, err)
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+			continue
+		}
+		break
+	}
+
+	n.metric.WithLabelValues(name, "failed").Add(float64(len(notifications)))
+	n.log.Errorf("error send %d notifications to %s / %s", len(notifications), name, err)
+	return false
+}
+
+func (n *notifier) silenced(notification Notification) bool {
+	for _, s := range n.silences {
+		if s.match(notification) {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicated check if the notification is sent to the receiver within the dedup window
+func (n *notifier) duplicated(receiver string, notification Notification) bool {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	ts, ok := n.sent[receiver+"|"+notification.key()]
+	return ok && time.Since(ts) < n.config.DedupWindow
+}
+
+// record notifications sent to a receiver, so that they are not sent again within the dedup window
+func (n *notifier) record(receiver string, notifications []Notification) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	now := time.Now()
+	for _, notification := range notifications {
+		n.sent[receiver+"|"+notification.key()] = now
+	}
+}
+
+// prune forget notifications sent before the dedup window
+func (n *notifier) prune() {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	now := time.Now()
+	for key, ts := range n.sent {
+		if now.Sub(ts) >= n.config.DedupWindow {
+			delete(n.sent, key)
+		}
+	}
+}
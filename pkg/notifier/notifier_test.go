@@ -0,0 +1,227 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+// receiver is an http receiver replying with the next status codes,
+// then 200
+type receiver struct {
+	*httptest.Server
+
+	mx       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		r.mx.Lock()
+		defer r.mx.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) count() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return len(r.bodies)
+}
+
+func newTestNotifier(t *testing.T, receivers ...Receiver) Notifier {
+	names := make([]string, len(receivers))
+	for i, r := range receivers {
+		names[i] = r.Name
+	}
+
+	n, err := New(Deps{Config: Config{
+		Rules: []Rule{{
+			Name:      "payments-egress",
+			Source:    Matcher{Namespace: "payments"},
+			Receivers: names,
+		}},
+		Receivers:   receivers,
+		Retry:       Retry{MaxAttempts: 3, Backoff: time.Millisecond},
+		DedupWindow: time.Hour,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func edgeDiff(port string) mapnode.Diff {
+	return mapnode.Diff{
+		Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Edges: []mapnode.EdgeEvent{{
+			Type:        mapnode.EdgeAdded,
+			Source:      "payments/api",
+			Destination: "billing/db",
+			Port:        port,
+		}},
+	}
+}
+
+func TestWebhookPayload(t *testing.T) {
+	r := newReceiver(t)
+	n := newTestNotifier(t, Receiver{
+		Name:    "hook",
+		Type:    ReceiverWebhook,
+		URL:     r.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+
+	sent := n.Notify(context.Background(), edgeDiff("5432"))
+	if len(sent) != 1 {
+		t.Fatalf("got %d sent notifications, want 1", len(sent))
+	}
+
+	if r.count() != 1 {
+		t.Fatalf("got %d requests, want 1", r.count())
+	}
+	if got := r.requests[0].Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("got authorization header %q", got)
+	}
+	if got := r.requests[0].Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got content type %q", got)
+	}
+
+	var payload struct {
+		Notifications []Notification `json:"notifications"`
+	}
+	if err := json.Unmarshal(r.bodies[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := Notification{
+		Rule:        "payments-egress",
+		Event:       mapnode.EdgeAdded,
+		Source:      "payments/api",
+		Destination: "billing/db",
+		Port:        "5432",
+		Time:        edgeDiff("5432").Time,
+	}
+	if len(payload.Notifications) != 1 || payload.Notifications[0] != want {
+		t.Errorf("got notifications %v, want %v", payload.Notifications, want)
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	r := newReceiver(t)
+	n := newTestNotifier(t, Receiver{Name: "slack", Type: ReceiverSlack, URL: r.URL})
+
+	n.Notify(context.Background(), edgeDiff("5432"))
+
+	if r.count() != 1 {
+		t.Fatalf("got %d requests, want 1", r.count())
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(r.bodies[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := "*telescope*: 1 dependency changes\n• [payments-egress] payments/api started calling billing/db on port 5432"
+	if payload["text"] != want {
+		t.Errorf("got text %q, want %q", payload["text"], want)
+	}
+}
+
+func TestAlertmanagerPayload(t *testing.T) {
+	r := newReceiver(t)
+	n := newTestNotifier(t, Receiver{Name: "am", Type: ReceiverAlertmanager, URL: r.URL})
+
+	n.Notify(context.Background(), edgeDiff("5432"))
+
+	if r.count() != 1 {
+		t.Fatalf("got %d requests, want 1", r.count())
+	}
+	if path := r.requests[0].URL.Path; path != "/api/v2/alerts" {
+		t.Errorf("got path %s, want /api/v2/alerts", path)
+	}
+	var alerts []alertmanagerAlert
+	if err := json.Unmarshal(r.bodies[0], &alerts); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	labels := alerts[0].Labels
+	if labels["alertname"] != "TelescopeNewDependency" || labels["source"] != "payments/api" ||
+		labels["destination"] != "billing/db" || labels["port"] != "5432" || labels["rule"] != "payments-egress" {
+		t.Errorf("got labels %v", labels)
+	}
+	if !alerts[0].StartsAt.Equal(edgeDiff("5432").Time) {
+		t.Errorf("got starts at %s", alerts[0].StartsAt)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		sent     bool
+	}{
+		{name: "server error", statuses: []int{500, 502}, requests: 3, sent: true},
+		{name: "throttled", statuses: []int{429}, requests: 2, sent: true},
+		{name: "client error", statuses: []int{400}, requests: 1, sent: false},
+		{name: "exhausted", statuses: []int{503, 503, 503}, requests: 3, sent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReceiver(t, tt.statuses...)
+			n := newTestNotifier(t, Receiver{Name: "hook", URL: r.URL})
+
+			sent := n.Notify(context.Background(), edgeDiff("5432"))
+			if r.count() != tt.requests {
+				t.Errorf("got %d requests, want %d", r.count(), tt.requests)
+			}
+			if (len(sent) == 1) != tt.sent {
+				t.Errorf("got sent notifications %v, want sent %v", sent, tt.sent)
+			}
+		})
+	}
+}
+
+func TestDedupAfterDelivery(t *testing.T) {
+	r := newReceiver(t, 400)
+	n := newTestNotifier(t, Receiver{Name: "hook", URL: r.URL})
+	ctx := context.Background()
+
+	// a failed delivery is not deduplicated
+	if sent := n.Notify(ctx, edgeDiff("5432")); len(sent) != 0 {
+		t.Fatalf("got sent notifications %v, want none", sent)
+	}
+	if sent := n.Notify(ctx, edgeDiff("5432")); len(sent) != 1 {
+		t.Fatalf("got %d sent notifications, want 1", len(sent))
+	}
+
+	// a delivered one is
+	if sent := n.Notify(ctx, edgeDiff("5432")); len(sent) != 0 {
+		t.Errorf("got duplicated notifications %v", sent)
+	}
+	if r.count() != 2 {
+		t.Errorf("got %d requests, want 2", r.count())
+	}
+}
@@ -0,0 +1,174 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danztran/telescope/pkg/httpclient"
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+const (
+	ReceiverWebhook      = "webhook"
+	ReceiverSlack        = "slack"
+	ReceiverAlertmanager = "alertmanager"
+)
+
+// Receiver is a destination of notifications
+type Receiver struct {
+	Name    string            `mapstructure:"name"`
+	Type    string            `mapstructure:"type"`
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+}
+
+type sender interface {
+	send(ctx context.Context, notifications []Notification) error
+}
+
+type httpSender struct {
+	config  Receiver
+	client  httpclient.Client
+	payload func([]Notification) interface{}
+	path    string
+}
+
+func newSender(cfg Receiver) (sender, error) {
+	if cfg.Name == "" || cfg.URL == "" {
+		return nil, fmt.Errorf("name and url are required")
+	}
+
+	client, err := httpclient.NewClient(httpclient.Config{
+		Address: cfg.URL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &httpSender{
+		config: cfg,
+		client: client,
+	}
+
+	switch cfg.Type {
+	case ReceiverWebhook, "":
+		s.payload = webhookPayload
+	case ReceiverSlack:
+		s.payload = slackPayload
+	case ReceiverAlertmanager:
+		s.payload = alertmanagerPayload
+		s.path = "/api/v2/alerts"
+	default:
+		return nil, fmt.Errorf("invalid type: %s", cfg.Type)
+	}
+
+	return s, nil
+}
+
+func (s *httpSender) send(ctx context.Context, notifications []Notification) error {
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(s.payload(notifications))
+	if err != nil {
+		return fmt.Errorf("error marshal payload / %w", err)
+	}
+
+	url := s.client.URL(s.path, nil)
+	req, err := http.NewRequest(http.MethodPost, url.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error create new request / %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, _, err := s.client.Do(ctx, req, nil)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return &errThrottled{err}
+	}
+	return err
+}
+
+// errThrottled is a rate limited delivery, retryable unlike other client errors
+type errThrottled struct {
+	err error
+}
+
+func (e *errThrottled) Error() string {
+	return fmt.Sprintf("throttled / %s", e.err)
+}
+
+func (e *errThrottled) Unwrap() error {
+	return e.err
+}
+
+// retryable check if a delivery error may not happen again,
+// client errors will, unless throttled
+func retryable(err error) bool {
+	var throttled *errThrottled
+	var errClient *httpclient.ErrClient
+	var errNotFound *httpclient.ErrNotFound
+	if errors.As(err, &throttled) {
+		return true
+	}
+	return !errors.As(err, &errClient) && !errors.As(err, &errNotFound)
+}
+
+func webhookPayload(notifications []Notification) interface{} {
+	return map[string]interface{}{
+		"notifications": notifications,
+	}
+}
+
+func slackPayload(notifications []Notification) interface{} {
+	lines := make([]string, len(notifications))
+	for i, n := range notifications {
+		lines[i] = "• " + n.String()
+	}
+	return map[string]interface{}{
+		"text": fmt.Sprintf("*telescope*: %d dependency changes\n%s", len(notifications), strings.Join(lines, "\n")),
+	}
+}
+
+// alertmanagerAlert is a postable alert of Alertmanager v2 API
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+}
+
+func alertmanagerPayload(notifications []Notification) interface{} {
+	alerts := make([]alertmanagerAlert, len(notifications))
+	for i, n := range notifications {
+		alertname := "TelescopeNewDependency"
+		if n.Event == mapnode.EdgeRemoved {
+			alertname = "TelescopeVanishedDependency"
+		}
+		alerts[i] = alertmanagerAlert{
+			Labels: map[string]string{
+				"alertname":   alertname,
+				"rule":        n.Rule,
+				"source":      n.Source,
+				"destination": n.Destination,
+				"port":        n.Port,
+			},
+			Annotations: map[string]string{
+				"summary": n.String(),
+			},
+			StartsAt: n.Time,
+		}
+	}
+	return alerts
+}
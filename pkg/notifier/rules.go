@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+// Rule select edge events to notify: events from a source to a destination,
+// on some ports, except to allowed destinations
+type Rule struct {
+	Name        string    `mapstructure:"name"`
+	Events      []string  `mapstructure:"events"`
+	Source      Matcher   `mapstructure:"source"`
+	Destination Matcher   `mapstructure:"destination"`
+	Ports       []string  `mapstructure:"ports"`
+	Allow       []Matcher `mapstructure:"allow"`
+	Receivers   []string  `mapstructure:"receivers"`
+}
+
+// Matcher match nodes by namespace & name regular expressions (fully matched),
+// and ports if it is used for destinations. Empty fields match anything.
+type Matcher struct {
+	Namespace string   `mapstructure:"namespace"`
+	Name      string   `mapstructure:"name"`
+	Ports     []string `mapstructure:"ports"`
+}
+
+// Silence mute notifications of a rule (any rule if empty) within a time window
+type Silence struct {
+	Rule        string  `mapstructure:"rule"`
+	Source      Matcher `mapstructure:"source"`
+	Destination Matcher `mapstructure:"destination"`
+	StartsAt    string  `mapstructure:"starts_at"`
+	EndsAt      string  `mapstructure:"ends_at"`
+	Comment     string  `mapstructure:"comment"`
+}
+
+type rule struct {
+	Rule
+	events      map[string]bool
+	ports       map[string]bool
+	source      *matcher
+	destination *matcher
+	allow       []*matcher
+}
+
+type matcher struct {
+	namespace *regexp.Regexp
+	name      *regexp.Regexp
+	ports     map[string]bool
+}
+
+type silence struct {
+	Silence
+	source      *matcher
+	destination *matcher
+	startsAt    time.Time
+	endsAt      time.Time
+}
+
+func newRule(cfg Rule) (*rule, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(cfg.Receivers) == 0 {
+		return nil, fmt.Errorf("receivers are required")
+	}
+
+	r := &rule{
+		Rule:   cfg,
+		events: map[string]bool{},
+		ports:  toSet(cfg.Ports),
+	}
+
+	if len(cfg.Events) == 0 {
+		r.events[mapnode.EdgeAdded] = true
+	}
+	for _, event := range cfg.Events {
+		if event != mapnode.EdgeAdded && event != mapnode.EdgeRemoved {
+			return nil, fmt.Errorf("invalid event: %s", event)
+		}
+		r.events[event] = true
+	}
+
+	var err error
+	if r.source, err = newMatcher(cfg.Source); err != nil {
+		return nil, fmt.Errorf("invalid source / %w", err)
+	}
+	if r.destination, err = newMatcher(cfg.Destination); err != nil {
+		return nil, fmt.Errorf("invalid destination / %w", err)
+	}
+	for i, allow := range cfg.Allow {
+		m, err := newMatcher(allow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow %d / %w", i, err)
+		}
+		r.allow = append(r.allow, m)
+	}
+
+	return r, nil
+}
+
+func (r *rule) match(edge mapnode.EdgeEvent) bool {
+	if !r.events[edge.Type] {
+		return false
+	}
+	if len(r.ports) > 0 && !r.ports[edge.Port] {
+		return false
+	}
+	if !r.source.match(edge.Source, "") || !r.destination.match(edge.Destination, edge.Port) {
+		return false
+	}
+	for _, allow := range r.allow {
+		if allow.match(edge.Destination, edge.Port) {
+			return false
+		}
+	}
+	return true
+}
+
+func newMatcher(cfg Matcher) (*matcher, error) {
+	m := &matcher{ports: toSet(cfg.Ports)}
+
+	var err error
+	if m.namespace, err = compileFull(cfg.Namespace); err != nil {
+		return nil, err
+	}
+	if m.name, err = compileFull(cfg.Name); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// match check a node id, and the port if set
+func (m *matcher) match(id string, port string) bool {
+	_, namespace, name := mapnode.SplitNodeID(id)
+	if m.namespace != nil && !m.namespace.MatchString(namespace) {
+		return false
+	}
+	if m.name != nil && !m.name.MatchString(name) {
+		return false
+	}
+	if port != "" && len(m.ports) > 0 && !m.ports[port] {
+		return false
+	}
+	return true
+}
+
+func newSilence(cfg Silence) (*silence, error) {
+	s := &silence{Silence: cfg}
+
+	var err error
+	if s.startsAt, err = time.Parse(time.RFC3339, cfg.StartsAt); err != nil {
+		return nil, fmt.Errorf("invalid starts_at / %w", err)
+	}
+	if s.endsAt, err = time.Parse(time.RFC3339, cfg.EndsAt); err != nil {
+		return nil, fmt.Errorf("invalid ends_at / %w", err)
+	}
+	if !s.endsAt.After(s.startsAt) {
+		return nil, fmt.Errorf("ends_at must be after starts_at")
+	}
+	if s.source, err = newMatcher(cfg.Source); err != nil {
+		return nil, fmt.Errorf("invalid source / %w", err)
+	}
+	if s.destination, err = newMatcher(cfg.Destination); err != nil {
+		return nil, fmt.Errorf("invalid destination / %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *silence) match(n Notification) bool {
+	if n.Time.Before(s.startsAt) || !n.Time.Before(s.endsAt) {
+		return false
+	}
+	if s.Rule != "" && s.Rule != n.Rule {
+		return false
+	}
+	return s.source.match(n.Source, "") && s.destination.match(n.Destination, n.Port)
+}
+
+// compileFull compile a regular expression matching whole strings,
+// nil for an empty expression
+func compileFull(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
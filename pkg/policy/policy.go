@@ -0,0 +1,261 @@
+// Package policy check observed dependencies against a declarative
+// policy of allowed edges, and report the violations.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/promscope"
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var defaultLogger = utils.MustGetLogger("policy")
+
+// origins of evaluated edges
+const (
+	OriginCollector string = "collector"
+	OriginMapnode   string = "mapnode"
+)
+
+type Deps struct {
+	Log       *zap.SugaredLogger
+	Collector collector.Collector
+	Mapnode   mapnode.Mapnode
+	// Metrics is the namespace & subsystem of exposed metrics
+	Metrics collector.Metrics
+	Config  Config
+}
+
+type Config struct {
+	File             string        `mapstructure:"file"`
+	EvaluateInterval time.Duration `mapstructure:"evaluate_interval"`
+	// Cluster name of the collector edges in the mapnode graph, if multi-cluster
+	Cluster string `mapstructure:"cluster"`
+}
+
+// Violation is an observed edge allowed by no rule
+type Violation struct {
+	Cluster              string   `json:"cluster,omitempty"`
+	Source               string   `json:"src"`
+	SourceNamespace      string   `json:"src_ns"`
+	Destination          string   `json:"dest"`
+	DestinationNamespace string   `json:"dest_ns"`
+	Port                 string   `json:"dest_port"`
+	Origins              []string `json:"origins"`
+}
+
+// Report is the result of an evaluation
+type Report struct {
+	Violations  []Violation `json:"violations"`
+	Edges       int         `json:"edges"`
+	EvaluatedAt time.Time   `json:"evaluated_at"`
+}
+
+type Policy interface {
+	Enabled() bool
+	Evaluate(ctx context.Context) Report
+	GetReport() Report
+	Run(ctx context.Context)
+}
+
+type policy struct {
+	config    Config
+	log       *zap.SugaredLogger
+	collector collector.Collector
+	mapnode   mapnode.Mapnode
+	rules     *rules
+	metric    *utils.GaugeSeries
+
+	mx     sync.RWMutex
+	report Report
+}
+
+// edge is an evaluated edge, without origin
+type edge struct {
+	Cluster              string
+	Source               string
+	SourceNamespace      string
+	Destination          string
+	DestinationNamespace string
+	Port                 string
+}
+
+func MustNew(deps Deps) Policy {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (Policy, error) {
+	config := deps.Config
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	if config.EvaluateInterval <= 0 {
+		config.EvaluateInterval = time.Minute
+	}
+
+	p := &policy{
+		config:    config,
+		log:       deps.Log,
+		collector: deps.Collector,
+		mapnode:   deps.Mapnode,
+		report:    Report{Violations: []Violation{}},
+	}
+
+	if config.File == "" {
+		return p, nil
+	}
+
+	file, err := LoadFile(config.File)
+	if err != nil {
+		return nil, err
+	}
+	if p.rules, err = newRules(*file); err != nil {
+		return nil, err
+	}
+
+	metric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      promscope.PolicyViolationMetric,
+		Subsystem: deps.Metrics.Subsystem,
+		Namespace: deps.Metrics.Namespace,
+		Help:      "Observed connections allowed by no policy rule.",
+	}, []string{"cluster", "src", "src_ns", "dest", "dest_ns", "dest_port", "origin"})
+
+	if err := prometheus.Register(metric); err != nil {
+		return nil, err
+	}
+	p.metric = utils.NewGaugeSeries(metric)
+
+	return p, nil
+}
+
+func (p *policy) Enabled() bool {
+	return p.rules != nil
+}
+
+// Run evaluate the policy in interval until the context is done
+func (p *policy) Run(ctx context.Context) {
+	if !p.Enabled() {
+		p.log.Info("disabled policy: no file")
+		return
+	}
+
+	utils.RunStateless(ctx, p.config.EvaluateInterval, func() {
+		p.Evaluate(ctx)
+	})
+}
+
+// Evaluate check the collector edges and the mapnode graph against the policy,
+// update violation metrics and keep the report
+func (p *policy) Evaluate(ctx context.Context) Report {
+	if !p.Enabled() {
+		return p.GetReport()
+	}
+
+	edges := map[edge][]string{}
+	if p.collector != nil {
+		for _, e := range p.collector.GetEdges() {
+			// collector edges are keyed in their cluster, to match the mapnode ones
+			key := edge{
+				Cluster:              p.config.Cluster,
+				Source:               e.Source,
+				SourceNamespace:      e.SourceNamespace,
+				Destination:          e.Destination,
+				DestinationNamespace: e.DestinationNamespace,
+				Port:                 e.DestinationPort,
+			}
+			edges[key] = appendOrigin(edges[key], OriginCollector)
+		}
+	}
+	if p.mapnode != nil {
+		for _, node := range p.mapnode.GetAllNodes() {
+			for _, out := range node.Outbounds {
+				key := edge{
+					Cluster:              node.Cluster,
+					Source:               node.Name,
+					SourceNamespace:      node.Namespace,
+					Destination:          out.Name,
+					DestinationNamespace: out.Namespace,
+					Port:                 out.Port,
+				}
+				edges[key] = appendOrigin(edges[key], OriginMapnode)
+			}
+		}
+	}
+
+	report := Report{
+		Violations:  []Violation{},
+		EvaluatedAt: time.Now(),
+	}
+	for e, origins := range edges {
+		if net.ParseIP(e.Destination) != nil || !p.rules.evaluated(e.SourceNamespace) {
+			continue
+		}
+		report.Edges++
+		if p.rules.allowed(e) {
+			continue
+		}
+		report.Violations = append(report.Violations, Violation{
+			Cluster:              e.Cluster,
+			Source:               e.Source,
+			SourceNamespace:      e.SourceNamespace,
+			Destination:          e.Destination,
+			DestinationNamespace: e.DestinationNamespace,
+			Port:                 e.Port,
+			Origins:              origins,
+		})
+	}
+	sort.Slice(report.Violations, func(i, j int) bool {
+		return report.Violations[i].key() < report.Violations[j].key()
+	})
+
+	for _, v := range report.Violations {
+		for _, origin := range v.Origins {
+			p.metric.Set(1, v.Cluster, v.Source, v.SourceNamespace,
+				v.Destination, v.DestinationNamespace, v.Port, origin)
+		}
+	}
+	p.metric.Commit()
+
+	p.log.Debugf("evaluated edges: %d, violations: %d", report.Edges, len(report.Violations))
+
+	p.mx.Lock()
+	p.report = report
+	p.mx.Unlock()
+
+	return report
+}
+
+// GetReport get the last evaluation report
+func (p *policy) GetReport() Report {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	return p.report
+}
+
+func (v Violation) key() string {
+	src := mapnode.NodeID(v.Cluster, v.SourceNamespace, v.Source)
+	dest := mapnode.NodeID(v.Cluster, v.DestinationNamespace, v.Destination)
+	return fmt.Sprintf("%s -> %s:%s", src, dest, v.Port)
+}
+
+func appendOrigin(origins []string, origin string) []string {
+	for _, o := range origins {
+		if o == origin {
+			return origins
+		}
+	}
+	return append(origins, origin)
+}
@@ -0,0 +1,169 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/viper"
+)
+
+// File is a policy file: the edges allowed between workloads.
+// Evaluated edges matching no allow rule are violations.
+type File struct {
+	// Namespaces limit evaluated edges to source namespaces
+	// matching any of these expressions, all if empty
+	Namespaces []string `mapstructure:"namespaces"`
+	Allow      []Rule   `mapstructure:"allow"`
+}
+
+// Rule allow edges from a workload to another on some ports (any if empty)
+type Rule struct {
+	Name  string   `mapstructure:"name"`
+	From  Selector `mapstructure:"from"`
+	To    Selector `mapstructure:"to"`
+	Ports []string `mapstructure:"ports"`
+}
+
+// Selector select workloads by namespace & name regular expressions (fully matched).
+// Empty fields match anything.
+type Selector struct {
+	Namespace string `mapstructure:"namespace"`
+	Workload  string `mapstructure:"workload"`
+}
+
+type rules struct {
+	namespaces []*regexp.Regexp
+	allow      []*rule
+}
+
+type rule struct {
+	Rule
+	from  *selector
+	to    *selector
+	ports map[string]bool
+}
+
+type selector struct {
+	namespace *regexp.Regexp
+	workload  *regexp.Regexp
+}
+
+// LoadFile read & validate a policy file
+func LoadFile(path string) (*File, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error read policy file / %w", err)
+	}
+
+	file := new(File)
+	if err := v.Unmarshal(file); err != nil {
+		return nil, fmt.Errorf("error parse policy file / %w", err)
+	}
+
+	if _, err := newRules(*file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func newRules(file File) (*rules, error) {
+	r := &rules{}
+
+	for _, expr := range file.Namespaces {
+		re, err := compileFull(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace %q / %w", expr, err)
+		}
+		if re != nil {
+			r.namespaces = append(r.namespaces, re)
+		}
+	}
+
+	for i, cfg := range file.Allow {
+		rl := &rule{Rule: cfg, ports: toSet(cfg.Ports)}
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+
+		var err error
+		if rl.from, err = newSelector(cfg.From); err != nil {
+			return nil, fmt.Errorf("allow %s: invalid from / %w", name, err)
+		}
+		if rl.to, err = newSelector(cfg.To); err != nil {
+			return nil, fmt.Errorf("allow %s: invalid to / %w", name, err)
+		}
+		r.allow = append(r.allow, rl)
+	}
+
+	return r, nil
+}
+
+// evaluated check whether an edge from the namespace is subject to the policy
+func (r *rules) evaluated(namespace string) bool {
+	if len(r.namespaces) == 0 {
+		return true
+	}
+	for _, re := range r.namespaces {
+		if re.MatchString(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed check whether any rule allow an edge
+func (r *rules) allowed(e edge) bool {
+	for _, rl := range r.allow {
+		if len(rl.ports) > 0 && !rl.ports[e.Port] {
+			continue
+		}
+		if rl.from.match(e.SourceNamespace, e.Source) && rl.to.match(e.DestinationNamespace, e.Destination) {
+			return true
+		}
+	}
+	return false
+}
+
+func newSelector(cfg Selector) (*selector, error) {
+	s := &selector{}
+
+	var err error
+	if s.namespace, err = compileFull(cfg.Namespace); err != nil {
+		return nil, err
+	}
+	if s.workload, err = compileFull(cfg.Workload); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *selector) match(namespace string, workload string) bool {
+	if s.namespace != nil && !s.namespace.MatchString(namespace) {
+		return false
+	}
+	if s.workload != nil && !s.workload.MatchString(workload) {
+		return false
+	}
+	return true
+}
+
+// compileFull compile a regular expression matching whole strings,
+// nil for an empty expression
+func compileFull(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,169 @@
+package promscope
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultChunkSize         = 6 * time.Hour
+	defaultMaxParallelChunks = 4
+)
+
+// chunkFunc get connections of a chunk range
+type chunkFunc func(ctx context.Context, r promv1.Range) ([]mapnode.Connection, error)
+
+// chunker split long ranges into chunks queried in parallel
+type chunker struct {
+	size     time.Duration
+	step     time.Duration
+	parallel int
+	flavor   string
+	log      *zap.SugaredLogger
+	metric   *prometheus.HistogramVec
+}
+
+func newChunker(deps Deps) (*chunker, error) {
+	config := deps.Config
+
+	c := &chunker{
+		size:     config.ChunkSize,
+		step:     config.GetConnectionsStep,
+		parallel: config.MaxParallelChunks,
+		flavor:   deps.Flavor,
+		log:      deps.Log,
+	}
+	if c.size <= 0 {
+		c.size = defaultChunkSize
+	}
+	if c.size < c.step {
+		c.size = c.step
+	}
+	// chunks hold whole steps so their evaluation points stay aligned
+	c.size = c.size.Truncate(c.step)
+	if c.parallel <= 0 {
+		c.parallel = defaultMaxParallelChunks
+	}
+	if c.flavor == "" {
+		c.flavor = FlavorPrometheus
+	}
+
+	metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      ChunkDurationMetric,
+		Subsystem: deps.MetricsSubsystem,
+		Namespace: deps.MetricsNamespace,
+		Help:      "Duration of promscope chunk queries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"flavor", "status"})
+
+	if err := prometheus.Register(metric); err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		metric = are.ExistingCollector.(*prometheus.HistogramVec)
+	}
+	c.metric = metric
+
+	return c, nil
+}
+
+// ranges split start-end into chunks of evaluation steps.
+// The end is rounded up to a whole step, so the last step looks back
+// over the end of the range.
+func (c *chunker) ranges(start time.Time, end time.Time) []promv1.Range {
+	step := c.step
+	end = start.Add((end.Sub(start) + step - 1) / step * step)
+
+	ranges := []promv1.Range{}
+	for s := start; !s.After(end); {
+		e := s.Add(c.size - step)
+		if e.After(end) {
+			e = end
+		}
+		ranges = append(ranges, promv1.Range{Start: s, End: e, Step: step})
+		s = e.Add(step)
+	}
+
+	return ranges
+}
+
+// run get connections of chunks in parallel, merged & deduped in range order.
+// The first error cancel the remaining chunks.
+func (c *chunker) run(ctx context.Context, start time.Time, end time.Time, fn chunkFunc) ([]mapnode.Connection, error) {
+	ranges := c.ranges(start, end)
+	results := make([][]mapnode.Connection, len(ranges))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		done     int32
+	)
+	sem := make(chan struct{}, c.parallel)
+
+	for i, r := range ranges {
+		wg.Add(1)
+		go func(i int, r promv1.Range) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			ts := time.Now()
+			conns, err := fn(ctx, r)
+			status := "ok"
+			if err != nil {
+				status = "error"
+			}
+			c.metric.WithLabelValues(c.flavor, status).Observe(time.Since(ts).Seconds())
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+
+			results[i] = conns
+			c.log.Debugf("got chunk %d/%d [start:%v] [end:%v]: %d connections",
+				atomic.AddInt32(&done, 1), len(ranges), r.Start, r.End, len(conns))
+		}(i, r)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	connections := []mapnode.Connection{}
+	seen := map[mapnode.Connection]bool{}
+	for _, conns := range results {
+		for _, conn := range conns {
+			if seen[conn] {
+				continue
+			}
+			seen[conn] = true
+			connections = append(connections, conn)
+		}
+	}
+
+	return connections, nil
+}
@@ -1,47 +1,21 @@
 package promscope
 
-import (
-	"fmt"
-
-	"github.com/danztran/telescope/pkg/mapnode"
-	"github.com/mitchellh/mapstructure"
-	"github.com/prometheus/common/model"
-)
-
 const (
 	ConnectionMetric string = "scope_connection"
 	DurationMetric   string = "scope_duration_seconds"
-)
-
-// Connection is a label set of scope metrics
-type Connection struct {
-	Topology             string `json:"topology" mapstructure:"topology"`
-	Source               string `json:"src" mapstructure:"src"`
-	SourceNamespace      string `json:"src_ns" mapstructure:"src_ns"`
-	Destination          string `json:"dest" mapstructure:"dest"`
-	DestinationNamespace string `json:"dest_ns" mapstructure:"dest_ns"`
-	DestinationPort      string `json:"dest_port" mapstructure:"dest_port"`
-}
 
-// convertToMapConnection convert labelset data to mapnode Connection
-func convertToMapConnection(set model.LabelSet) (*mapnode.Connection, error) {
-	conn := new(Connection)
-	data := map[string]string{}
-	for k, v := range set {
-		data[string(k)] = string(v)
-	}
+	NodesFetchedMetric string = "scope_collector_nodes_fetched_total"
+	NodeCacheMetric    string = "scope_collector_node_cache_requests_total"
+	EdgesEmittedMetric string = "scope_collector_edges_emitted_total"
+	EdgesDroppedMetric string = "scope_collector_edges_dropped_total"
+	KubeLookupsMetric  string = "scope_collector_kube_lookups_total"
 
-	if err := mapstructure.Decode(data, conn); err != nil {
-		return nil, fmt.Errorf("error decode connection: %+v / %w", set, err)
-	}
+	ChunkDurationMetric string = "scope_promscope_chunk_duration_seconds"
 
-	mapconn := mapnode.Connection{
-		Source:               conn.Source,
-		SourceNamespace:      conn.SourceNamespace,
-		Destination:          conn.Destination,
-		DestinationNamespace: conn.DestinationNamespace,
-		DestinationPort:      conn.DestinationPort,
-	}
+	RemoteWriteSeriesMetric string = "scope_remote_write_series_total"
 
-	return &mapconn, nil
-}
+	PolicyViolationMetric   string = "scope_connection_policy_violation"
+	NetpolDeniedMetric      string = "scope_connection_netpol_denied"
+	NetpolDefaultDenyMetric string = "scope_connection_netpol_default_deny_blocked"
+	NetpolUnusedRulesMetric string = "scope_netpol_unused_rules"
+)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/danztran/telescope/pkg/mapnode"
 	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/api"
 	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"go.uber.org/zap"
@@ -16,19 +17,58 @@ import (
 
 var defaultLogger = utils.MustGetLogger("promscope")
 
+// flavors of Prometheus-compatible APIs
+const (
+	FlavorPrometheus      string = "prometheus"
+	FlavorThanos          string = "thanos"
+	FlavorCortex          string = "cortex"
+	FlavorMimir           string = "mimir"
+	FlavorVictoriaMetrics string = "victoriametrics"
+)
+
+// tenantHeaders are default tenant headers of flavors
+var tenantHeaders = map[string]string{
+	FlavorThanos: "THANOS-TENANT",
+	FlavorCortex: "X-Scope-OrgID",
+	FlavorMimir:  "X-Scope-OrgID",
+}
+
 type Deps struct {
 	Log    *zap.SugaredLogger
 	Config Config
+	// Flavor of the API, prometheus if empty
+	Flavor string
+	// Metric is the connection metric name registered by the collector,
+	// with its namespace & subsystem prefix
+	Metric string
+	// MetricsNamespace & MetricsSubsystem prefix the exposed metrics
+	MetricsNamespace string
+	MetricsSubsystem string
 }
 
 type Config struct {
 	Prometheus         Prometheus    `mapstructure:"prometheus"`
 	GetConnectionsStep time.Duration `mapstructure:"get_connections_step"`
+	// ChunkSize split ranges into queries of at most this size, 6h by default
+	ChunkSize time.Duration `mapstructure:"chunk_size"`
+	// MaxParallelChunks is the number of chunks queried at once, 4 by default
+	MaxParallelChunks int `mapstructure:"max_parallel_chunks"`
+	// Query is a text/template of the connections query, with QueryData
+	Query string `mapstructure:"query"`
+	// Metric override the connection metric name
+	Metric string `mapstructure:"metric"`
+	// Labels map connection fields to label names, defaulting to DefaultLabels
+	Labels Labels `mapstructure:"labels"`
 }
 
 type Prometheus struct {
 	Address string  `mapstructure:"address"`
 	Token   *string `mapstructure:"token"`
+	// Tenant is sent in the tenant header of multi-tenant APIs
+	Tenant string `mapstructure:"tenant"`
+	// TenantHeader override the default tenant header of the flavor
+	TenantHeader string            `mapstructure:"tenant_header"`
+	Headers      map[string]string `mapstructure:"headers"`
 }
 
 type Promscope interface {
@@ -39,6 +79,8 @@ type promscope struct {
 	config  Config
 	log     *zap.SugaredLogger
 	promAPI promv1.API
+	query   *connQuery
+	chunker *chunker
 }
 
 func MustNew(deps Deps) Promscope {
@@ -49,36 +91,65 @@ func MustNew(deps Deps) Promscope {
 	return c
 }
 
-// New create new promscope interface
+// New create new promscope interface, querying a Prometheus-compatible API
 func New(deps Deps) (Promscope, error) {
 	config := deps.Config
 
-	promAPI := promv1.NewAPI(nil)
+	switch deps.Flavor {
+	case FlavorPrometheus, FlavorThanos, FlavorCortex, FlavorMimir, "":
+	case FlavorVictoriaMetrics:
+		return NewVictoriaMetrics(deps)
+	default:
+		return nil, fmt.Errorf("unknown flavor: %s", deps.Flavor)
+	}
 
 	if deps.Log == nil {
 		deps.Log = defaultLogger
 	}
 
+	query, err := newConnQuery(config, deps.Metric)
+	if err != nil {
+		return nil, err
+	}
+
+	chunker, err := newChunker(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := api.NewClient(api.Config{
+		Address:      config.Prometheus.Address,
+		RoundTripper: newRoundTripper(config.Prometheus, deps.Flavor),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error create prometheus client / %w", err)
+	}
+
+	promAPI := promv1.NewAPI(client)
+
 	p := &promscope{
 		config:  config,
 		log:     deps.Log,
 		promAPI: promAPI,
+		query:   query,
+		chunker: chunker,
 	}
 
 	return p, nil
 }
 
 // GetConnections get scope connections metrics from prometheus
-// and parse to Connection model
+// and parse to Connection model, querying chunks of the range in parallel
 func (p *promscope) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
 	defer utils.LogDuration()(p.log, "GetConnections with [start:%v] [end:%v]", start, end)
 
-	query := fmt.Sprintf("sum (%s) by (src, dest, dest_port, topology)", ConnectionMetric)
-	val, warns, err := p.promAPI.QueryRange(ctx, query, promv1.Range{
-		Start: start,
-		End:   end,
-		Step:  p.config.GetConnectionsStep,
-	})
+	return p.chunker.run(ctx, start, end, p.getChunk)
+}
+
+// getChunk get connections of a chunk range
+func (p *promscope) getChunk(ctx context.Context, r promv1.Range) ([]mapnode.Connection, error) {
+	query := p.query.query
+	val, warns, err := p.promAPI.QueryRange(ctx, query, r)
 	if err != nil {
 		return nil, fmt.Errorf("error query series: %s / %w", query, err)
 	}
@@ -94,12 +165,7 @@ func (p *promscope) GetConnections(ctx context.Context, start time.Time, end tim
 	connections := make([]mapnode.Connection, len(matrix))
 	p.log.Debugf("found %d matrix streams", len(connections))
 	for i, v := range matrix {
-		lbSet := model.LabelSet(v.Metric)
-		conn, err := convertToMapConnection(lbSet)
-		if err != nil {
-			return nil, fmt.Errorf("error convert to map connection / %w", err)
-		}
-		connections[i] = *conn
+		connections[i] = p.query.connection(model.LabelSet(v.Metric))
 	}
 
 	return connections, nil
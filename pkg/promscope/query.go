@@ -0,0 +1,146 @@
+package promscope
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/prometheus/common/model"
+)
+
+// DefaultQuery is the default template of the connections query.
+// Each step counts samples over the whole step window, so series scraped
+// between evaluation points are not missed.
+const DefaultQuery string = "sum by ({{.Labels}}) (count_over_time({{.Metric}}[{{.Window}}]))"
+
+// Labels map connection fields to metric label names
+type Labels struct {
+	Cluster              string `mapstructure:"cluster"`
+	Source               string `mapstructure:"source"`
+	SourceNamespace      string `mapstructure:"source_namespace"`
+	Destination          string `mapstructure:"destination"`
+	DestinationNamespace string `mapstructure:"destination_namespace"`
+	DestinationPort      string `mapstructure:"destination_port"`
+}
+
+// DefaultLabels are label names exposed by the collector
+var DefaultLabels = Labels{
+	Cluster:              "cluster",
+	Source:               "src",
+	SourceNamespace:      "src_ns",
+	Destination:          "dest",
+	DestinationNamespace: "dest_ns",
+	DestinationPort:      "dest_port",
+}
+
+// QueryData is the data of query templates
+type QueryData struct {
+	// Metric is the connection metric name
+	Metric string
+	// Labels are the mapped label names, comma separated
+	Labels string
+	// Window is the step of range queries, as a PromQL duration
+	Window string
+}
+
+// connQuery is a validated connections query & label mapping
+type connQuery struct {
+	metric string
+	labels Labels
+	query  string
+}
+
+// newConnQuery validate & render the connections query of a config.
+// The metric name is config.Metric if set, else the given metric.
+func newConnQuery(config Config, metric string) (*connQuery, error) {
+	if config.Metric != "" {
+		metric = config.Metric
+	}
+	if metric == "" {
+		metric = ConnectionMetric
+	}
+	if config.GetConnectionsStep <= 0 {
+		return nil, fmt.Errorf("get_connections_step must be positive: %v", config.GetConnectionsStep)
+	}
+	if !model.IsValidMetricName(model.LabelValue(metric)) {
+		return nil, fmt.Errorf("invalid metric name: %q", metric)
+	}
+
+	labels := config.Labels
+	defaults := DefaultLabels
+	fields := []struct {
+		name  string
+		value *string
+		def   string
+	}{
+		{"cluster", &labels.Cluster, defaults.Cluster},
+		{"source", &labels.Source, defaults.Source},
+		{"source_namespace", &labels.SourceNamespace, defaults.SourceNamespace},
+		{"destination", &labels.Destination, defaults.Destination},
+		{"destination_namespace", &labels.DestinationNamespace, defaults.DestinationNamespace},
+		{"destination_port", &labels.DestinationPort, defaults.DestinationPort},
+	}
+	names := make([]string, 0, len(fields))
+	mapped := map[string]string{}
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = f.def
+		}
+		if !model.LabelName(*f.value).IsValid() {
+			return nil, fmt.Errorf("invalid label name of %s: %q", f.name, *f.value)
+		}
+		if other, ok := mapped[*f.value]; ok {
+			return nil, fmt.Errorf("label %q is mapped to both %s and %s", *f.value, other, f.name)
+		}
+		mapped[*f.value] = f.name
+		names = append(names, *f.value)
+	}
+
+	text := config.Query
+	if text == "" {
+		text = DefaultQuery
+	}
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query template / %w", err)
+	}
+	buf := new(bytes.Buffer)
+	err = tmpl.Execute(buf, QueryData{
+		Metric: metric,
+		Labels: strings.Join(names, ", "),
+		Window: model.Duration(config.GetConnectionsStep).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid query template / %w", err)
+	}
+	query := strings.TrimSpace(buf.String())
+	if !strings.Contains(query, metric) {
+		return nil, fmt.Errorf("query does not use metric %s: %s", metric, query)
+	}
+
+	q := &connQuery{
+		metric: metric,
+		labels: labels,
+		query:  query,
+	}
+
+	return q, nil
+}
+
+// connection map a label set to a mapnode connection
+func (q *connQuery) connection(set model.LabelSet) mapnode.Connection {
+	get := func(name string) string {
+		return string(set[model.LabelName(name)])
+	}
+
+	return mapnode.Connection{
+		Cluster:              get(q.labels.Cluster),
+		Source:               get(q.labels.Source),
+		SourceNamespace:      get(q.labels.SourceNamespace),
+		Destination:          get(q.labels.Destination),
+		DestinationNamespace: get(q.labels.DestinationNamespace),
+		DestinationPort:      get(q.labels.DestinationPort),
+	}
+}
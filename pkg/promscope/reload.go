@@ -0,0 +1,83 @@
+package promscope
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+)
+
+// Reloadable is a Promscope created again on config reloads
+type Reloadable interface {
+	Promscope
+	// Reload replace the Promscope with one of a config,
+	// keeping the current one if the config is invalid
+	Reload(config Config) error
+}
+
+type reloadable struct {
+	deps Deps
+
+	mx      sync.RWMutex
+	current Promscope
+}
+
+// NewReloadable create a Promscope of deps, reloadable with other configs
+func NewReloadable(deps Deps) (Reloadable, error) {
+	current, err := New(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reloadable{
+		deps:    deps,
+		current: current,
+	}
+
+	return r, nil
+}
+
+func (r *reloadable) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	deps := r.deps
+	deps.Config = config
+	current, err := New(deps)
+	if err != nil {
+		return err
+	}
+
+	r.mx.Lock()
+	r.current = current
+	r.mx.Unlock()
+
+	return nil
+}
+
+// GetConnections get connections with the current Promscope,
+// running queries completing with the one they started with
+func (r *reloadable) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
+	r.mx.RLock()
+	current := r.current
+	r.mx.RUnlock()
+
+	return current.GetConnections(ctx, start, end)
+}
+
+// Validate check the step & chunking of queries
+func (config Config) Validate() error {
+	if config.GetConnectionsStep <= 0 {
+		return fmt.Errorf("get_connections_step must be positive: %v", config.GetConnectionsStep)
+	}
+	if config.ChunkSize < 0 {
+		return fmt.Errorf("chunk_size must not be negative: %v", config.ChunkSize)
+	}
+	if config.MaxParallelChunks < 0 {
+		return fmt.Errorf("max_parallel_chunks must not be negative: %d", config.MaxParallelChunks)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package promscope
+
+import (
+	"net/http"
+
+	"github.com/danztran/telescope/pkg/httpclient"
+)
+
+// roundTripper set authorization, tenant & custom headers on requests
+type roundTripper struct {
+	next    http.RoundTripper
+	headers map[string]string
+}
+
+func newRoundTripper(config Prometheus, flavor string) http.RoundTripper {
+	headers := map[string]string{}
+	for k, v := range config.Headers {
+		headers[k] = v
+	}
+	if config.Token != nil && *config.Token != "" {
+		headers["Authorization"] = "Bearer " + *config.Token
+	}
+	if config.Tenant != "" {
+		header := config.TenantHeader
+		if header == "" {
+			header = tenantHeaders[flavor]
+		}
+		if header == "" {
+			header = tenantHeaders[FlavorCortex]
+		}
+		headers[header] = config.Tenant
+	}
+
+	return &roundTripper{
+		next:    httpclient.DefaultRoundTripper,
+		headers: headers,
+	}
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(rt.headers) == 0 {
+		return rt.next.RoundTrip(req)
+	}
+
+	// a round tripper must not modify the request
+	req = req.Clone(req.Context())
+	for k, v := range rt.headers {
+		req.Header.Set(k, v)
+	}
+
+	return rt.next.RoundTrip(req)
+}
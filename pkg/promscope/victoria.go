@@ -0,0 +1,138 @@
+package promscope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/utils"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+)
+
+// victoria is a promscope getting connections from the export API
+// of VictoriaMetrics, streaming series instead of querying a range
+type victoria struct {
+	config  Config
+	log     *zap.SugaredLogger
+	client  *http.Client
+	query   *connQuery
+	chunker *chunker
+}
+
+// exportLine is a series of the export API, samples being ignored
+type exportLine struct {
+	Metric map[string]string `json:"metric"`
+}
+
+// NewVictoriaMetrics create a promscope using the VictoriaMetrics export API
+func NewVictoriaMetrics(deps Deps) (Promscope, error) {
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	if _, err := url.Parse(deps.Config.Prometheus.Address); err != nil {
+		return nil, fmt.Errorf("invalid address / %w", err)
+	}
+	if deps.Config.Query != "" {
+		// series are exported as is, no query runs on them
+		return nil, fmt.Errorf("query is not supported by the %s flavor", FlavorVictoriaMetrics)
+	}
+
+	query, err := newConnQuery(deps.Config, deps.Metric)
+	if err != nil {
+		return nil, err
+	}
+
+	chunker, err := newChunker(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &victoria{
+		config: deps.Config,
+		log:    deps.Log,
+		client: &http.Client{
+			Transport: newRoundTripper(deps.Config.Prometheus, FlavorVictoriaMetrics),
+		},
+		query:   query,
+		chunker: chunker,
+	}
+
+	return v, nil
+}
+
+// GetConnections export series of scope connections between start & end,
+// and parse their distinct label sets to Connection model, exporting chunks
+// of the range in parallel
+func (v *victoria) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
+	defer utils.LogDuration()(v.log, "GetConnections with [start:%v] [end:%v]", start, end)
+
+	return v.chunker.run(ctx, start, end, v.exportChunk)
+}
+
+// exportChunk export series of a chunk range, including the step before
+// its first evaluation point as range queries do
+func (v *victoria) exportChunk(ctx context.Context, r promv1.Range) ([]mapnode.Connection, error) {
+	start, end := r.Start.Add(-r.Step), r.End
+
+	u, err := url.Parse(strings.TrimRight(v.config.Prometheus.Address, "/") + "/api/v1/export")
+	if err != nil {
+		return nil, fmt.Errorf("invalid address / %w", err)
+	}
+	match := fmt.Sprintf(`{__name__=%q}`, v.query.metric)
+	u.RawQuery = url.Values{
+		"match[]": {match},
+		"start":   {strconv.FormatInt(start.Unix(), 10)},
+		"end":     {strconv.FormatInt(end.Unix(), 10)},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error export series: %s / %w", match, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("error export series: %s / (%d) %s", match, resp.StatusCode, body)
+	}
+
+	connections := []mapnode.Connection{}
+	seen := map[mapnode.Connection]bool{}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line exportLine
+		if err := decoder.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error decode exported series / %w", err)
+		}
+
+		set := model.LabelSet{}
+		for k, val := range line.Metric {
+			set[model.LabelName(k)] = model.LabelValue(val)
+		}
+		conn := v.query.connection(set)
+		if seen[conn] {
+			continue
+		}
+		seen[conn] = true
+		connections = append(connections, conn)
+	}
+	v.log.Debugf("found %d exported series", len(connections))
+
+	return connections, nil
+}
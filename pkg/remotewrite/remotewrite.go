@@ -0,0 +1,315 @@
+// Package remotewrite push metrics exposed by the collector to a remote-write
+// endpoint, for clusters without Prometheus scraping telescope.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/httpclient"
+	"github.com/danztran/telescope/pkg/promscope"
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+)
+
+var defaultLogger = utils.MustGetLogger("remotewrite")
+
+const (
+	defaultInterval  = time.Minute
+	defaultTimeout   = 30 * time.Second
+	defaultBatchSize = 1000
+)
+
+// DefaultMetrics are the pushed edge metrics
+var DefaultMetrics = []string{
+	promscope.ConnectionMetric,
+	promscope.PolicyViolationMetric,
+	promscope.NetpolDeniedMetric,
+	promscope.NetpolDefaultDenyMetric,
+}
+
+type Deps struct {
+	Log *zap.SugaredLogger
+	// Gatherer of pushed metrics, the default registry if nil
+	Gatherer prometheus.Gatherer
+	// Metrics is the collector metrics config, with its remote write
+	Metrics collector.Metrics
+}
+
+type RemoteWrite interface {
+	Push(ctx context.Context) error
+	Run(ctx context.Context)
+}
+
+type remoteWrite struct {
+	config   collector.RemoteWrite
+	log      *zap.SugaredLogger
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	metrics  map[string]bool
+	metric   *prometheus.CounterVec
+}
+
+func MustNew(deps Deps) RemoteWrite {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (RemoteWrite, error) {
+	config := deps.Metrics.RemoteWrite
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	if deps.Gatherer == nil {
+		deps.Gatherer = prometheus.DefaultGatherer
+	}
+	if config.URL != "" {
+		if _, err := url.Parse(config.URL); err != nil {
+			return nil, fmt.Errorf("invalid url / %w", err)
+		}
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.Retry.MaxAttempts <= 0 {
+		config.Retry.MaxAttempts = 1
+	}
+	for name := range config.ExternalLabels {
+		if !model.LabelName(name).IsValid() || name == model.MetricNameLabel {
+			return nil, fmt.Errorf("invalid external label name: %q", name)
+		}
+	}
+
+	names := config.Metrics
+	if len(names) == 0 {
+		names = DefaultMetrics
+	}
+	metrics := make(map[string]bool, len(names))
+	for _, name := range names {
+		metrics[deps.Metrics.Name(name)] = true
+	}
+
+	metric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      promscope.RemoteWriteSeriesMetric,
+		Subsystem: deps.Metrics.Subsystem,
+		Namespace: deps.Metrics.Namespace,
+		Help:      "Series pushed to the remote-write endpoint, by delivery status.",
+	}, []string{"status"})
+
+	if err := prometheus.Register(metric); err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		metric = are.ExistingCollector.(*prometheus.CounterVec)
+	}
+
+	w := &remoteWrite{
+		config:   config,
+		log:      deps.Log,
+		gatherer: deps.Gatherer,
+		client:   &http.Client{Transport: httpclient.DefaultRoundTripper},
+		metrics:  metrics,
+		metric:   metric,
+	}
+
+	return w, nil
+}
+
+// Run push metrics every interval until the context is done
+func (w *remoteWrite) Run(ctx context.Context) {
+	if w.config.URL == "" {
+		w.log.Info("disabled remote write: no url")
+		return
+	}
+
+	utils.RunStateful(ctx, w.config.Interval, func() {
+		err := w.Push(ctx)
+		if err != nil {
+			w.log.Error(err)
+		}
+	})
+}
+
+// Push gather the current samples of pushed metrics
+// and send them in batches of series
+func (w *remoteWrite) Push(ctx context.Context) error {
+	if w.config.URL == "" {
+		return nil
+	}
+	defer utils.LogDuration()(w.log, "pushing metrics to %s", w.config.URL)
+
+	all, err := w.gather()
+	if err != nil {
+		return err
+	}
+
+	var failed error
+	for i := 0; i < len(all); i += w.config.BatchSize {
+		end := i + w.config.BatchSize
+		if end > len(all) {
+			end = len(all)
+		}
+		batch := all[i:end]
+
+		if err := w.deliver(ctx, batch); err != nil {
+			w.metric.WithLabelValues("failed").Add(float64(len(batch)))
+			failed = err
+			continue
+		}
+		w.metric.WithLabelValues("sent").Add(float64(len(batch)))
+	}
+	if failed != nil {
+		return fmt.Errorf("error push metrics to %s / %w", w.config.URL, failed)
+	}
+
+	w.log.Debugf("pushed %d series to %s", len(all), w.config.URL)
+	return nil
+}
+
+// gather convert samples of pushed metrics to series with external labels.
+// Only counter, gauge & untyped metrics are pushed.
+func (w *remoteWrite) gather() ([]series, error) {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("error gather metrics / %w", err)
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	all := []series{}
+	for _, family := range families {
+		name := family.GetName()
+		if !w.metrics[name] {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				w.log.Debugf("ignored metric %s of type %s", name, family.GetType())
+				continue
+			}
+
+			ts := now
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+
+			// empty labels are absent labels in prometheus
+			set := map[string]string{}
+			for _, pair := range m.GetLabel() {
+				if pair.GetValue() != "" {
+					set[pair.GetName()] = pair.GetValue()
+				}
+			}
+			// external labels do not override labels of the series
+			for k, v := range w.config.ExternalLabels {
+				if _, ok := set[k]; !ok {
+					set[k] = v
+				}
+			}
+			set[model.MetricNameLabel] = name
+
+			labels := make([]label, 0, len(set))
+			for k, v := range set {
+				labels = append(labels, label{Name: k, Value: v})
+			}
+			all = append(all, series{Labels: labels, Value: value, Timestamp: ts})
+		}
+	}
+
+	return all, nil
+}
+
+// deliver send a batch, retrying server errors & throttling with exponential backoff
+func (w *remoteWrite) deliver(ctx context.Context, batch []series) error {
+	body := snappy.Encode(nil, marshalWriteRequest(batch))
+	backoff := w.config.Retry.Backoff
+
+	var err error
+	for attempt := 1; attempt <= w.config.Retry.MaxAttempts; attempt++ {
+		var retry bool
+		retry, err = w.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == w.config.Retry.MaxAttempts {
+			break
+		}
+
+		w.log.Warnf("error push %d series (attempt %d) / %s", len(batch), attempt, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+
+	return err
+}
+
+// send post an encoded write request, returning if a failure is retryable
+func (w *remoteWrite) send(ctx context.Context, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("error create new request / %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("User-Agent", "telescope")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range w.config.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.config.Token != nil && *w.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+*w.config.Token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("(%d) %s", resp.StatusCode, bytes.TrimSpace(msg))
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+	return retry, err
+}
@@ -0,0 +1,274 @@
+package remotewrite
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// receiver is a remote-write endpoint replying with the next status codes,
+// then 204, and decoding the received write requests
+type receiver struct {
+	*httptest.Server
+
+	mx       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	requests [][]series
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		data, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("error decode snappy body / %s", err)
+		}
+		batch, err := unmarshalWriteRequest(data)
+		if err != nil {
+			t.Errorf("error unmarshal write request / %s", err)
+		}
+
+		r.mx.Lock()
+		defer r.mx.Unlock()
+		r.headers = append(r.headers, req.Header)
+		r.requests = append(r.requests, batch)
+
+		status := http.StatusNoContent
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// unmarshalWriteRequest decode a prometheus.WriteRequest protobuf
+func unmarshalWriteRequest(data []byte) ([]series, error) {
+	batch := []series{}
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, v []byte, u uint64) error {
+		if num != 1 {
+			return nil
+		}
+		s := series{}
+		err := consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte, u uint64) error {
+			switch num {
+			case 1:
+				l := label{}
+				err := consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte, u uint64) error {
+					if num == 1 {
+						l.Name = string(v)
+					} else {
+						l.Value = string(v)
+					}
+					return nil
+				})
+				s.Labels = append(s.Labels, l)
+				return err
+			case 2:
+				return consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte, u uint64) error {
+					if num == 1 {
+						s.Value = math.Float64frombits(u)
+					} else {
+						s.Timestamp = int64(u)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		batch = append(batch, s)
+		return err
+	})
+	return batch, err
+}
+
+// consumeFields call fn with each field of a message,
+// the bytes of length-delimited fields or the number of others
+func consumeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, u uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var v []byte
+		var u uint64
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(data)
+		case protowire.Fixed64Type:
+			u, n = protowire.ConsumeFixed64(data)
+		case protowire.VarintType:
+			u, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if err := fn(num, typ, v, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *receiver) received() ([]http.Header, [][]series) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.headers, r.requests
+}
+
+func labelsOf(s series) map[string]string {
+	labels := map[string]string{}
+	for _, l := range s.Labels {
+		labels[l.Name] = l.Value
+	}
+	return labels
+}
+
+func newTestRemoteWrite(t *testing.T, url string, config collector.RemoteWrite, edges ...[]string) RemoteWrite {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "scope_connection",
+		Namespace: "telescope",
+	}, []string{"cluster", "src", "dest"})
+	registry.MustRegister(gauge)
+	for _, edge := range edges {
+		gauge.WithLabelValues(edge...).Set(1)
+	}
+
+	config.URL = url
+	token := "secret"
+	config.Token = &token
+	config.Retry.Backoff = time.Millisecond
+
+	w, err := New(Deps{
+		Gatherer: registry,
+		Metrics: collector.Metrics{
+			Namespace:   "telescope",
+			RemoteWrite: config,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestPush(t *testing.T) {
+	r := newReceiver(t)
+	w := newTestRemoteWrite(t, r.URL, collector.RemoteWrite{
+		BatchSize:      2,
+		Headers:        map[string]string{"X-Scope-OrgID": "tenant"},
+		ExternalLabels: map[string]string{"cluster": "prod", "replica": "a"},
+	},
+		[]string{"", "api", "db"},
+		[]string{"staging", "web", "api"},
+		[]string{"", "web", "cache"},
+	)
+
+	if err := w.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	headers, requests := r.received()
+	if len(requests) != 2 || len(requests[0]) != 2 || len(requests[1]) != 1 {
+		t.Fatalf("got batches %v, want 2 then 1 series", requests)
+	}
+
+	for _, h := range headers {
+		for k, want := range map[string]string{
+			"Content-Type":                      "application/x-protobuf",
+			"Content-Encoding":                  "snappy",
+			"X-Prometheus-Remote-Write-Version": "0.1.0",
+			"Authorization":                     "Bearer secret",
+			"X-Scope-OrgID":                     "tenant",
+		} {
+			if got := h.Get(k); got != want {
+				t.Errorf("got header %s %q, want %q", k, got, want)
+			}
+		}
+	}
+
+	clusters := map[string]string{}
+	for _, batch := range requests {
+		for _, s := range batch {
+			names := make([]string, len(s.Labels))
+			for i, l := range s.Labels {
+				names[i] = l.Name
+			}
+			if !sort.StringsAreSorted(names) {
+				t.Errorf("got unsorted labels %v", names)
+			}
+
+			labels := labelsOf(s)
+			if labels["__name__"] != "telescope_scope_connection" {
+				t.Errorf("got metric %s", labels["__name__"])
+			}
+			if labels["replica"] != "a" {
+				t.Errorf("got no external label replica: %v", labels)
+			}
+			if s.Value != 1 || s.Timestamp == 0 {
+				t.Errorf("got sample %v at %d", s.Value, s.Timestamp)
+			}
+			clusters[labels["src"]+"->"+labels["dest"]] = labels["cluster"]
+		}
+	}
+
+	// external labels do not override labels of series
+	want := map[string]string{"api->db": "prod", "web->api": "staging", "web->cache": "prod"}
+	for edge, cluster := range want {
+		if clusters[edge] != cluster {
+			t.Errorf("got cluster %q of %s, want %q", clusters[edge], edge, cluster)
+		}
+	}
+}
+
+func TestPushRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		failed   bool
+	}{
+		{name: "server error", statuses: []int{500, 503}, requests: 3},
+		{name: "throttled", statuses: []int{429}, requests: 2},
+		{name: "client error", statuses: []int{400}, requests: 1, failed: true},
+		{name: "exhausted", statuses: []int{500, 500, 500}, requests: 3, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReceiver(t, tt.statuses...)
+			w := newTestRemoteWrite(t, r.URL, collector.RemoteWrite{
+				Retry: collector.RemoteWriteRetry{MaxAttempts: 3},
+			}, []string{"", "api", "db"})
+
+			err := w.Push(context.Background())
+			if (err != nil) != tt.failed {
+				t.Errorf("got error %v, want failed %v", err, tt.failed)
+			}
+			if _, requests := r.received(); len(requests) != tt.requests {
+				t.Errorf("got %d requests, want %d", len(requests), tt.requests)
+			}
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/danztran/telescope/pkg/httpclient"
 	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -18,11 +20,17 @@ const (
 
 	LabelDocker = "docker_label_"
 	LabelPodUID = "label_io.kubernetes.pod.uid"
+
+	// RequestMetric is the duration histogram of Scope API requests
+	RequestMetric = "scope_request_duration_seconds"
 )
 
 type Deps struct {
 	Log    *zap.SugaredLogger
 	Config Config
+	// MetricsNamespace & MetricsSubsystem prefix the exposed metrics
+	MetricsNamespace string
+	MetricsSubsystem string
 }
 
 type Config struct {
@@ -38,6 +46,7 @@ type scope struct {
 	log    *zap.SugaredLogger
 	client httpclient.Client
 	config Config
+	metric *prometheus.HistogramVec
 }
 
 func MustNew(deps Deps) Scope {
@@ -62,17 +71,31 @@ func New(deps Deps) (Scope, error) {
 		deps.Log = defaultLogger
 	}
 
+	metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      RequestMetric,
+		Subsystem: deps.MetricsSubsystem,
+		Namespace: deps.MetricsNamespace,
+		Help:      "Duration of Scope API requests, by endpoint & status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "status"})
+
+	if err := prometheus.Register(metric); err != nil {
+		return nil, err
+	}
+
 	c := &scope{
 		config: deps.Config,
 		log:    deps.Log,
 		client: httpclient,
+		metric: metric,
 	}
 
 	return c, nil
 }
 
-func (s *scope) GetTopology(ctx context.Context, topologyID string) (*APITopology, error) {
+func (s *scope) GetTopology(ctx context.Context, topologyID string) (_ *APITopology, err error) {
 	defer utils.LogDuration()(s.log, "GetTopology %s", topologyID)
+	defer s.observe("topology", time.Now(), &err)
 
 	url := s.client.URL("/api/topology/:topology", map[string]string{
 		"topology": topologyID,
@@ -93,8 +116,9 @@ func (s *scope) GetTopology(ctx context.Context, topologyID string) (*APITopolog
 	return apiTopology, nil
 }
 
-func (s *scope) GetNode(ctx context.Context, topologyID string, nodeID string) (*APINode, error) {
+func (s *scope) GetNode(ctx context.Context, topologyID string, nodeID string) (_ *APINode, err error) {
 	defer utils.LogDuration()(s.log, "GetNode %s/%s", topologyID, nodeID)
+	defer s.observe("node", time.Now(), &err)
 
 	url := s.client.URL("/api/topology/:topology/:nodeID", map[string]string{
 		"topology": topologyID,
@@ -115,3 +139,16 @@ func (s *scope) GetNode(ctx context.Context, topologyID string, nodeID string) (
 
 	return apiNode, nil
 }
+
+// observe the duration of a request to an endpoint since ts, with its error status
+func (s *scope) observe(endpoint string, ts time.Time, err *error) {
+	status := "ok"
+	switch {
+	case *err == nil:
+	case utils.IsErrNotFound(*err):
+		status = "not_found"
+	default:
+		status = "error"
+	}
+	s.metric.WithLabelValues(endpoint, status).Observe(time.Since(ts).Seconds())
+}
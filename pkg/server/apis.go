@@ -1,31 +1,74 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/danztran/telescope/pkg/handler"
 	"github.com/danztran/telescope/pkg/httpclient"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/meshexport"
+	"github.com/danztran/telescope/pkg/meshql"
+	"github.com/danztran/telescope/pkg/netpol"
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func (s *server) setupAPIs(e *echo.Echo) error {
-	e.GET("/health", func(c echo.Context) error { return c.String(http.StatusOK, "OK") })
+	e.GET("/health", s.health)
 	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
 
 	v1Public := e.Group("/v1/public")
 	v1Public.GET("/mesh", wrapHandler(s.getAllConnections))
 	v1Public.GET("/mesh/:name", wrapHandler(s.getConnectionsByName))
+	v1Public.GET("/mesh/:name/downstream", wrapHandler(s.getDownstream))
+	v1Public.GET("/mesh/:name/upstream", wrapHandler(s.getUpstream))
+	v1Public.GET("/mesh/:name/path/:other", wrapHandler(s.getPath))
+	// graph-wide queries stay out of /mesh/:name, not to shadow nodes
+	v1Public.GET("/cycles", wrapHandler(s.getCycles))
+	v1Public.GET("/events", wrapHandler(s.getEvents))
+	v1Public.GET("/updates/:id", wrapHandler(s.getUpdateJob))
+	v1Public.GET("/policy/violations", wrapHandler(s.getPolicyViolations))
+	v1Public.GET("/netpol", wrapHandler(s.getNetworkPolicies))
+	v1Public.GET("/netpol/audit", wrapHandler(s.getNetworkPolicyAudit))
+	v1Public.GET("/history/edges", wrapHandler(s.getEdgeHistory))
+
+	v1Debug := e.Group("/v1/debug")
+	v1Debug.GET("/edges", wrapHandler(s.explainEdges))
+
+	if s.meshql != nil {
+		v1Public.GET("/graphql", wrapHandler(s.graphql))
+		v1Public.POST("/graphql", wrapHandler(s.graphql))
+	}
 
 	return nil
 }
 
+// health is OK for all replicas, along with their role if elected
+func (s *server) health(c echo.Context) error {
+	if s.elector == nil {
+		return c.String(http.StatusOK, "OK")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"status":   "OK",
+		"role":     s.elector.Role(),
+		"identity": s.elector.Identity(),
+	})
+}
+
 func (s *server) getConnectionsByName(c echo.Context) error {
 	name := c.Param("name")
+	opt := new(handler.GetNodeOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
 
 	ctx := c.Request().Context()
-	data, err := s.handler.GetConnectionsByName(ctx, name)
+	data, err := s.handler.GetConnectionsByName(ctx, name, *opt)
 	if err != nil {
 		return err
 	}
@@ -41,14 +84,209 @@ func (s *server) getAllConnections(c echo.Context) error {
 		return err
 	}
 
+	if opt.Format == "" {
+		opt.Format = string(meshexport.FormatFromAccept(c.Request().Header.Get(echo.HeaderAccept)))
+	}
+	if opt.Format != "" {
+		data, err := s.handler.ExportConnections(ctx, *opt)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, data.ContentType, data.Data)
+	}
+
 	data, err := s.handler.GetAllConnections(ctx, *opt)
 	if err != nil {
 		return err
 	}
 
+	if data.UpdateJob != nil && data.UpdateJob.Status == mapnode.UpdateRunning {
+		return c.JSON(http.StatusAccepted, data)
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getDownstream(c echo.Context) error {
+	name := c.Param("name")
+	opt := new(handler.GetTraversalOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	data, err := s.handler.GetDownstream(ctx, name, *opt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getUpstream(c echo.Context) error {
+	name := c.Param("name")
+	opt := new(handler.GetTraversalOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	data, err := s.handler.GetUpstream(ctx, name, *opt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getPath(c echo.Context) error {
+	name := c.Param("name")
+	other := c.Param("other")
+	opt := new(handler.GetPathOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	data, err := s.handler.GetPath(ctx, name, other, *opt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getCycles(c echo.Context) error {
+	ctx := c.Request().Context()
+	data, err := s.handler.GetCycles(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getUpdateJob(c echo.Context) error {
+	id := c.Param("id")
+
+	ctx := c.Request().Context()
+	data, err := s.handler.GetUpdateJob(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getPolicyViolations(c echo.Context) error {
+	opt := new(handler.GetViolationsOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	data, err := s.handler.GetPolicyViolations(ctx, *opt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getNetworkPolicies(c echo.Context) error {
+	opt := new(handler.GetNetworkPoliciesOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	if opt.Format == "yaml" || c.Request().Header.Get(echo.HeaderAccept) == netpol.ContentType {
+		data, err := s.handler.ExportNetworkPolicies(ctx, *opt)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, data.ContentType, data.Data)
+	}
+
+	data, err := s.handler.GetNetworkPolicies(ctx, *opt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getNetworkPolicyAudit(c echo.Context) error {
+	opt := new(handler.GetNetworkPolicyAuditOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	data, err := s.handler.GetNetworkPolicyAudit(ctx, *opt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) getEdgeHistory(c echo.Context) error {
+	opt := new(handler.GetEdgeHistoryOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	data, err := s.handler.GetEdgeHistory(ctx, *opt)
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(http.StatusOK, data)
 }
 
+func (s *server) explainEdges(c echo.Context) error {
+	opt := new(handler.ExplainEdgesOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	data, err := s.handler.ExplainEdges(ctx, *opt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *server) graphql(c echo.Context) error {
+	req := new(meshql.Request)
+
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	if variables := c.QueryParam("variables"); variables != "" {
+		if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+			return &httpclient.ErrClient{Message: fmt.Sprintf("invalid variables / %s", err)}
+		}
+	}
+
+	ctx := c.Request().Context()
+	result := s.meshql.Execute(ctx, *req)
+
+	return c.JSON(http.StatusOK, result)
+}
+
 func wrapHandler(hl func(echo.Context) error) func(echo.Context) error {
 	return func(c echo.Context) error {
 		err := hl(c)
@@ -61,10 +299,13 @@ func wrapHandler(hl func(echo.Context) error) func(echo.Context) error {
 
 func catchHandlerError(c echo.Context, err error) error {
 	var errNotFound *httpclient.ErrNotFound
+	var errClient *httpclient.ErrClient
 
 	switch true {
 	case errors.As(err, &errNotFound):
 		err = c.String(http.StatusNotFound, err.Error())
+	case errors.As(err, &errClient):
+		err = c.String(http.StatusBadRequest, err.Error())
 	default:
 		c.Error(err)
 	}
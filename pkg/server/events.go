@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danztran/telescope/pkg/handler"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	eventsPath = "/v1/public/events"
+
+	// eventsKeepAlive is the interval of keep alive messages,
+	// so that proxies do not close idle streams
+	eventsKeepAlive = 30 * time.Second
+)
+
+// getEvents stream mesh diffs over websocket if requested, server-sent events otherwise
+func (s *server) getEvents(c echo.Context) error {
+	opt := new(handler.GetEventsOptions)
+
+	if err := c.Bind(opt); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(c.Request().Header.Get(echo.HeaderUpgrade), "websocket") {
+		wsServer := websocket.Server{
+			Handshake: s.checkOrigin,
+			Handler: func(ws *websocket.Conn) {
+				defer ws.Close()
+				s.streamEventsWebsocket(c, ws, *opt)
+			},
+		}
+		wsServer.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+
+	return s.streamEventsSSE(c, *opt)
+}
+
+func (s *server) streamEventsSSE(c echo.Context, opt handler.GetEventsOptions) error {
+	ctx := c.Request().Context()
+	diffs, unsubscribe := s.handler.SubscribeEvents(ctx, opt)
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-s.done:
+			return nil
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+
+		case diff, ok := <-diffs:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(diff)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(res, "id: %d\nevent: diff\ndata: %s\n\n", diff.Time.UnixNano(), data)
+			if err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+func (s *server) streamEventsWebsocket(c echo.Context, ws *websocket.Conn, opt handler.GetEventsOptions) {
+	ctx := c.Request().Context()
+	diffs, unsubscribe := s.handler.SubscribeEvents(ctx, opt)
+	defer unsubscribe()
+
+	// the stream is one way, reading only detects closed connections
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case <-s.done:
+			return
+
+		case <-keepAlive.C:
+			ping := map[string]string{"type": "keep-alive"}
+			if err := websocket.JSON.Send(ws, ping); err != nil {
+				return
+			}
+
+		case diff, ok := <-diffs:
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(ws, diff); err != nil {
+				s.log.Debugf("error send mesh diff over websocket / %s", err)
+				return
+			}
+		}
+	}
+}
+
+// checkOrigin accept websockets from the request host or the cors origins,
+// so that other sites cannot open streams with the browser credentials.
+// Clients without origin are not browsers, accepted like any http client.
+func (s *server) checkOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin: %s / %w", origin, err)
+	}
+	config.Origin = u
+	if u.Host == req.Host {
+		return nil
+	}
+
+	if s.config.CORS {
+		for _, allowed := range s.config.CORSOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("origin not allowed: %s", origin)
+}
+
+func isStreamRequest(c echo.Context) bool {
+	return c.Request().URL.Path == eventsPath
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		origin  string
+		allowed bool
+	}{
+		{name: "no origin", origin: "", allowed: true},
+		{name: "same host", origin: "http://telescope:9090", allowed: true},
+		{name: "other site", origin: "https://evil.example.com", allowed: false},
+		{name: "other site with cors", config: Config{CORS: true}, origin: "https://evil.example.com", allowed: false},
+		{
+			name:    "listed origin",
+			config:  Config{CORS: true, CORSOrigins: []string{"https://ui.example.com"}},
+			origin:  "https://ui.example.com",
+			allowed: true,
+		},
+		{
+			name:    "listed origin without cors",
+			config:  Config{CORSOrigins: []string{"https://ui.example.com"}},
+			origin:  "https://ui.example.com",
+			allowed: false,
+		},
+		{
+			name:    "any origin",
+			config:  Config{CORS: true, CORSOrigins: []string{"*"}},
+			origin:  "https://evil.example.com",
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &server{config: tt.config}
+			req := httptest.NewRequest("GET", "http://telescope:9090"+eventsPath, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			err := s.checkOrigin(&websocket.Config{}, req)
+			if (err == nil) != tt.allowed {
+				t.Errorf("got error %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
@@ -52,11 +52,14 @@ func LogRequest(config LogConfig) echo.MiddlewareFunc {
 			}
 			req.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
 
-			// Response
+			// Response, dumped only if logged so that streams are not buffered
 			resBody := new(bytes.Buffer)
-			mw := io.MultiWriter(res.Writer, resBody)
-			writer := &bodyDumpWriter{Writer: mw, ResponseWriter: res.Writer}
-			res.Writer = writer
+			withResponseBody := config.WithResponseBody != nil && config.WithResponseBody(c)
+			if withResponseBody {
+				mw := io.MultiWriter(res.Writer, resBody)
+				writer := &bodyDumpWriter{Writer: mw, ResponseWriter: res.Writer}
+				res.Writer = writer
+			}
 
 			correlationID := req.Header.Get("x-correlation-id")
 			req.Header.Set("x-correlation-id", correlationID)
@@ -75,7 +78,7 @@ func LogRequest(config LogConfig) echo.MiddlewareFunc {
 				message += fmt.Sprintf(` request_body="%s"`, string(reqBody))
 			}
 
-			if config.WithResponseBody != nil && config.WithResponseBody(c) {
+			if withResponseBody {
 				message += fmt.Sprintf(` response_body="%s"`, strings.TrimSuffix(resBody.String(), "\n"))
 			}
 
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/danztran/telescope/pkg/election"
 	"github.com/danztran/telescope/pkg/handler"
+	"github.com/danztran/telescope/pkg/meshql"
 	"github.com/danztran/telescope/pkg/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -19,6 +21,9 @@ type Deps struct {
 	Log     *zap.SugaredLogger
 	Config  Config
 	Handler handler.Handler
+	MeshQL  meshql.MeshQL
+	// Elector tell the replica role in health checks, if set
+	Elector election.Elector
 }
 
 type Config struct {
@@ -27,7 +32,11 @@ type Config struct {
 	LogRequest      bool   `mapstructure:"log_request"`
 	LogResponse     bool   `mapstructure:"log_response"`
 	CORS            bool   `mapstructure:"cors"`
-	Pprof           bool   `mapstructure:"pprof"`
+	// CORSOrigins are the allowed origins of cross-origin requests, all if empty,
+	// websockets accepting only listed ones or their own host
+	CORSOrigins []string `mapstructure:"cors_origins"`
+	Pprof       bool     `mapstructure:"pprof"`
+	UI          bool     `mapstructure:"ui"`
 }
 
 type Server interface {
@@ -38,6 +47,11 @@ type server struct {
 	config  Config
 	log     *zap.SugaredLogger
 	handler handler.Handler
+	meshql  meshql.MeshQL
+	elector election.Elector
+
+	// done is closed when the server is shutting down, to end streams
+	done <-chan struct{}
 }
 
 func MustNew(deps Deps) Server {
@@ -60,6 +74,8 @@ func New(deps Deps) (Server, error) {
 		config:  deps.Config,
 		log:     deps.Log,
 		handler: deps.Handler,
+		meshql:  deps.MeshQL,
+		elector: deps.Elector,
 	}
 
 	return s, nil
@@ -67,9 +83,16 @@ func New(deps Deps) (Server, error) {
 
 func (s *server) Run(ctx context.Context) error {
 	e := echo.New()
+	s.done = ctx.Done()
 
 	if s.config.CORS {
-		e.Use(middleware.CORS())
+		if len(s.config.CORSOrigins) > 0 {
+			e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+				AllowOrigins: s.config.CORSOrigins,
+			}))
+		} else {
+			e.Use(middleware.CORS())
+		}
 	}
 	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
 		StackSize: 1 << 10, // 1 KB
@@ -87,7 +110,8 @@ func (s *server) Run(ctx context.Context) error {
 			return s.config.LogRequest
 		},
 		WithResponseBody: func(c echo.Context) bool {
-			return s.config.LogResponse && c.Request().RequestURI != "/metrics"
+			return s.config.LogResponse && c.Request().RequestURI != "/metrics" &&
+				!isUIRequest(c) && !isStreamRequest(c)
 		},
 	}))
 
@@ -95,6 +119,12 @@ func (s *server) Run(ctx context.Context) error {
 		return err
 	}
 
+	if s.config.UI {
+		if err := s.setupUI(e); err != nil {
+			return err
+		}
+	}
+
 	return s.listen(ctx, e)
 }
 
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const uiPath = "/ui/"
+
+//go:embed ui
+var uiAssets embed.FS
+
+// setupUI serve the embedded mesh visualization assets
+func (s *server) setupUI(e *echo.Echo) error {
+	assets, err := fs.Sub(uiAssets, "ui")
+	if err != nil {
+		return err
+	}
+
+	fileServer := http.StripPrefix(uiPath, http.FileServer(http.FS(assets)))
+	e.GET(strings.TrimSuffix(uiPath, "/"), func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, uiPath)
+	})
+	e.GET(uiPath+"*", echo.WrapHandler(fileServer))
+
+	return nil
+}
+
+func isUIRequest(c echo.Context) bool {
+	return strings.HasPrefix(c.Request().URL.Path, uiPath)
+}
@@ -0,0 +1,379 @@
+(function () {
+  'use strict';
+
+  var api = '../v1/public/mesh';
+  var svgNS = 'http://www.w3.org/2000/svg';
+
+  var state = {
+    nodes: [],        // {id, name, namespace, cluster, x, y, vx, vy}
+    edges: [],        // {id, source, target, ports}
+    byID: {},
+    hiddenNamespaces: {},
+    visible: null,    // set of node ids shown in focus view, null shows all
+    focus: null,
+    search: '',
+    port: '',
+    view: { x: 0, y: 0, k: 1 },
+  };
+
+  var el = {
+    svg: document.getElementById('graph'),
+    viewport: document.getElementById('viewport'),
+    edges: document.getElementById('edges'),
+    nodes: document.getElementById('nodes'),
+    search: document.getElementById('search'),
+    port: document.getElementById('port'),
+    reset: document.getElementById('reset'),
+    status: document.getElementById('status'),
+    namespaces: document.getElementById('namespace-list'),
+    details: document.getElementById('details'),
+    detailsTitle: document.getElementById('details-title'),
+    detailsNamespace: document.getElementById('details-namespace'),
+    detailsOutbounds: document.getElementById('details-outbounds'),
+    detailsInbounds: document.getElementById('details-inbounds'),
+  };
+
+  function color(namespace) {
+    var hash = 0;
+    for (var i = 0; i < namespace.length; i++) {
+      hash = (hash * 31 + namespace.charCodeAt(i)) | 0;
+    }
+    return 'hsl(' + (Math.abs(hash) % 360) + ', 60%, 55%)';
+  }
+
+  function namespaceOf(node) {
+    return node.cluster ? node.cluster + '/' + node.namespace : node.namespace;
+  }
+
+  function load() {
+    el.status.textContent = 'loading...';
+    fetch(api + '?format=cytoscape')
+      .then(function (resp) {
+        if (!resp.ok) {
+          throw new Error(resp.status + ' ' + resp.statusText);
+        }
+        return resp.json();
+      })
+      .then(function (data) {
+        var elements = data.elements;
+        state.nodes = elements.nodes
+          .filter(function (n) { return n.data.parent; })
+          .map(function (n) {
+            return {
+              id: n.data.id,
+              name: n.data.label,
+              namespace: n.data.namespace,
+              cluster: n.data.cluster,
+            };
+          });
+        state.byID = {};
+        state.nodes.forEach(function (n) { state.byID[n.id] = n; });
+        state.edges = elements.edges.map(function (e) {
+          return {
+            id: e.data.id,
+            source: e.data.source,
+            target: e.data.target,
+            ports: e.data.label ? e.data.label.split(', ') : [],
+          };
+        });
+        el.status.textContent = state.nodes.length + ' nodes, ' + state.edges.length + ' edges';
+        renderNamespaces();
+        renderPorts();
+        layout();
+        render();
+      })
+      .catch(function (err) {
+        el.status.textContent = 'error: ' + err.message;
+      });
+  }
+
+  function isVisible(node) {
+    if (state.hiddenNamespaces[namespaceOf(node)]) {
+      return false;
+    }
+    return !state.visible || state.visible[node.id];
+  }
+
+  // force directed layout, nodes are pulled to their namespace centroid
+  function layout() {
+    var nodes = state.nodes.filter(isVisible);
+    var width = el.svg.clientWidth || 800;
+    var height = el.svg.clientHeight || 600;
+    var namespaces = {};
+    nodes.forEach(function (n) { namespaces[namespaceOf(n)] = true; });
+    var names = Object.keys(namespaces).sort();
+    var radius = Math.min(width, height) / 3;
+    var anchors = {};
+    names.forEach(function (ns, i) {
+      var angle = (2 * Math.PI * i) / names.length;
+      anchors[ns] = {
+        x: width / 2 + (names.length > 1 ? radius * Math.cos(angle) : 0),
+        y: height / 2 + (names.length > 1 ? radius * Math.sin(angle) : 0),
+      };
+    });
+    nodes.forEach(function (n) {
+      var a = anchors[namespaceOf(n)];
+      n.x = a.x + (Math.random() - 0.5) * 100;
+      n.y = a.y + (Math.random() - 0.5) * 100;
+    });
+
+    var edges = state.edges.filter(function (e) {
+      return isVisible(state.byID[e.source]) && isVisible(state.byID[e.target]);
+    });
+
+    for (var iter = 0; iter < 300; iter++) {
+      var alpha = 1 - iter / 300;
+      nodes.forEach(function (n) { n.vx = 0; n.vy = 0; });
+      for (var i = 0; i < nodes.length; i++) {
+        for (var j = i + 1; j < nodes.length; j++) {
+          var a = nodes[i];
+          var b = nodes[j];
+          var dx = a.x - b.x || 0.01;
+          var dy = a.y - b.y || 0.01;
+          var d2 = dx * dx + dy * dy;
+          if (d2 > 90000) {
+            continue;
+          }
+          var f = 400 / d2;
+          a.vx += dx * f; a.vy += dy * f;
+          b.vx -= dx * f; b.vy -= dy * f;
+        }
+      }
+      edges.forEach(function (e) {
+        var s = state.byID[e.source];
+        var t = state.byID[e.target];
+        var dx = t.x - s.x;
+        var dy = t.y - s.y;
+        var d = Math.sqrt(dx * dx + dy * dy) || 1;
+        var f = (d - 80) / d * 0.05;
+        s.vx += dx * f; s.vy += dy * f;
+        t.vx -= dx * f; t.vy -= dy * f;
+      });
+      nodes.forEach(function (n) {
+        var a = anchors[namespaceOf(n)];
+        n.vx += (a.x - n.x) * 0.02;
+        n.vy += (a.y - n.y) * 0.02;
+        n.x += Math.max(-20, Math.min(20, n.vx)) * alpha;
+        n.y += Math.max(-20, Math.min(20, n.vy)) * alpha;
+      });
+    }
+  }
+
+  function svg(tag, attrs) {
+    var node = document.createElementNS(svgNS, tag);
+    Object.keys(attrs || {}).forEach(function (k) { node.setAttribute(k, attrs[k]); });
+    return node;
+  }
+
+  function render() {
+    el.edges.textContent = '';
+    el.nodes.textContent = '';
+    var search = state.search.toLowerCase();
+
+    state.edges.forEach(function (e) {
+      var s = state.byID[e.source];
+      var t = state.byID[e.target];
+      if (!isVisible(s) || !isVisible(t)) {
+        return;
+      }
+      var line = svg('line', { x1: s.x, y1: s.y, x2: t.x, y2: t.y, 'class': 'edge' });
+      if (state.port) {
+        line.classList.add(e.ports.indexOf(state.port) >= 0 ? 'highlight' : 'dim');
+      }
+      var title = svg('title');
+      title.textContent = e.source + ' -> ' + e.target + (e.ports.length ? ' :' + e.ports.join(', ') : '');
+      line.appendChild(title);
+      el.edges.appendChild(line);
+    });
+
+    state.nodes.forEach(function (n) {
+      if (!isVisible(n)) {
+        return;
+      }
+      var g = svg('g', { 'class': 'node', transform: 'translate(' + n.x + ',' + n.y + ')' });
+      if (search) {
+        g.classList.add(n.id.toLowerCase().indexOf(search) >= 0 ? 'match' : 'dim');
+      }
+      if (state.focus === n.id) {
+        g.classList.add('focus');
+      }
+      var circle = svg('circle', { r: 7, fill: color(namespaceOf(n)) });
+      var title = svg('title');
+      title.textContent = n.id;
+      circle.appendChild(title);
+      var text = svg('text', { x: 10, y: 4 });
+      text.textContent = n.name;
+      g.appendChild(circle);
+      g.appendChild(text);
+      g.addEventListener('click', function (ev) {
+        ev.stopPropagation();
+        focus(n.id);
+      });
+      el.nodes.appendChild(g);
+    });
+
+    applyView();
+  }
+
+  function renderNamespaces() {
+    var namespaces = {};
+    state.nodes.forEach(function (n) {
+      var ns = namespaceOf(n);
+      namespaces[ns] = (namespaces[ns] || 0) + 1;
+    });
+    el.namespaces.textContent = '';
+    Object.keys(namespaces).sort().forEach(function (ns) {
+      var li = document.createElement('li');
+      var checkbox = document.createElement('input');
+      checkbox.type = 'checkbox';
+      checkbox.checked = !state.hiddenNamespaces[ns];
+      checkbox.addEventListener('change', function () {
+        state.hiddenNamespaces[ns] = !checkbox.checked;
+        layout();
+        render();
+      });
+      var swatch = document.createElement('span');
+      swatch.className = 'swatch';
+      swatch.style.background = color(ns);
+      var label = document.createElement('span');
+      label.textContent = ns + ' (' + namespaces[ns] + ')';
+      li.appendChild(checkbox);
+      li.appendChild(swatch);
+      li.appendChild(label);
+      el.namespaces.appendChild(li);
+    });
+  }
+
+  function renderPorts() {
+    var ports = {};
+    state.edges.forEach(function (e) {
+      e.ports.forEach(function (p) { ports[p] = true; });
+    });
+    while (el.port.options.length > 1) {
+      el.port.remove(1);
+    }
+    Object.keys(ports)
+      .sort(function (a, b) { return Number(a) - Number(b) || a.localeCompare(b); })
+      .forEach(function (p) {
+        var option = document.createElement('option');
+        option.value = p;
+        option.textContent = p;
+        el.port.appendChild(option);
+      });
+  }
+
+  // focus show the node with its direct neighbors, details come from the node endpoint
+  function focus(id) {
+    var node = state.byID[id];
+    if (!node) {
+      return;
+    }
+    var params = new URLSearchParams({ namespace: node.namespace });
+    if (node.cluster) {
+      params.set('cluster', node.cluster);
+    }
+    fetch(api + '/' + encodeURIComponent(node.name) + '?' + params.toString())
+      .then(function (resp) {
+        if (!resp.ok) {
+          throw new Error(resp.status + ' ' + resp.statusText);
+        }
+        return resp.json();
+      })
+      .then(function (data) {
+        var detail = data.nodes[0];
+        state.focus = id;
+        state.visible = {};
+        state.visible[id] = true;
+        detail.outbounds.forEach(function (o) { state.visible[o.id] = true; });
+        detail.inbounds.forEach(function (i) { state.visible[i.id] = true; });
+        renderDetails(detail);
+        layout();
+        render();
+      })
+      .catch(function (err) {
+        el.status.textContent = 'error: ' + err.message;
+      });
+  }
+
+  function renderDetails(node) {
+    el.details.hidden = false;
+    el.detailsTitle.textContent = node.name;
+    el.detailsNamespace.textContent = node.id;
+    var list = function (ul, items, label) {
+      ul.textContent = '';
+      items.forEach(function (item) {
+        var li = document.createElement('li');
+        li.textContent = label(item);
+        li.addEventListener('click', function () { focus(item.id); });
+        ul.appendChild(li);
+      });
+    };
+    list(el.detailsOutbounds, node.outbounds, function (o) { return o.id + ' :' + o.port; });
+    list(el.detailsInbounds, node.inbounds, function (i) { return i.id; });
+  }
+
+  function reset() {
+    state.focus = null;
+    state.visible = null;
+    el.details.hidden = true;
+    layout();
+    render();
+  }
+
+  function applyView() {
+    var v = state.view;
+    el.viewport.setAttribute('transform', 'translate(' + v.x + ',' + v.y + ') scale(' + v.k + ')');
+  }
+
+  // pan & zoom
+  (function () {
+    var drag = null;
+    el.svg.addEventListener('mousedown', function (ev) {
+      drag = { x: ev.clientX - state.view.x, y: ev.clientY - state.view.y };
+    });
+    window.addEventListener('mousemove', function (ev) {
+      if (!drag) {
+        return;
+      }
+      state.view.x = ev.clientX - drag.x;
+      state.view.y = ev.clientY - drag.y;
+      applyView();
+    });
+    window.addEventListener('mouseup', function () { drag = null; });
+    el.svg.addEventListener('wheel', function (ev) {
+      ev.preventDefault();
+      var k = Math.max(0.1, Math.min(8, state.view.k * (ev.deltaY < 0 ? 1.1 : 0.9)));
+      var rect = el.svg.getBoundingClientRect();
+      var mx = ev.clientX - rect.left;
+      var my = ev.clientY - rect.top;
+      state.view.x = mx - (mx - state.view.x) * (k / state.view.k);
+      state.view.y = my - (my - state.view.y) * (k / state.view.k);
+      state.view.k = k;
+      applyView();
+    }, { passive: false });
+  })();
+
+  el.search.addEventListener('input', function () {
+    state.search = el.search.value.trim();
+    render();
+  });
+  el.search.addEventListener('keydown', function (ev) {
+    if (ev.key !== 'Enter' || !state.search) {
+      return;
+    }
+    var search = state.search.toLowerCase();
+    var match = state.nodes.filter(function (n) {
+      return n.id.toLowerCase().indexOf(search) >= 0;
+    })[0];
+    if (match) {
+      focus(match.id);
+    }
+  });
+  el.port.addEventListener('change', function () {
+    state.port = el.port.value;
+    render();
+  });
+  el.reset.addEventListener('click', reset);
+
+  load();
+})();
@@ -0,0 +1,145 @@
+package shard
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	coordination "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LabelGroup label Leases of the members of a shard group
+const LabelGroup = "telescope.io/shard-group"
+
+// discovery get the current members of the shard group
+type discovery interface {
+	members(ctx context.Context) ([]string, error)
+	// leave the group on shutdown
+	leave(ctx context.Context) error
+}
+
+// staticDiscovery is a fixed list of members
+type staticDiscovery struct {
+	list []string
+}
+
+func (d *staticDiscovery) members(ctx context.Context) ([]string, error) {
+	return d.list, nil
+}
+
+func (d *staticDiscovery) leave(ctx context.Context) error {
+	return nil
+}
+
+// dnsDiscovery resolve the pod IPs of a headless Service
+type dnsDiscovery struct {
+	service  string
+	resolver *net.Resolver
+}
+
+func (d *dnsDiscovery) members(ctx context.Context) ([]string, error) {
+	addrs, err := d.resolver.LookupHost(ctx, d.service)
+	if err != nil {
+		return nil, fmt.Errorf("error lookup service %s / %w", d.service, err)
+	}
+	return addrs, nil
+}
+
+func (d *dnsDiscovery) leave(ctx context.Context) error {
+	return nil
+}
+
+// leaseDiscovery renew a Lease per member, members being the holders
+// of unexpired Leases of the group
+type leaseDiscovery struct {
+	client    kubernetes.Interface
+	namespace string
+	group     string
+	identity  string
+	duration  time.Duration
+}
+
+func (d *leaseDiscovery) name() string {
+	return d.group + "-" + d.identity
+}
+
+func (d *leaseDiscovery) members(ctx context.Context) ([]string, error) {
+	if err := d.renew(ctx); err != nil {
+		return nil, err
+	}
+
+	leases, err := d.client.CoordinationV1().Leases(d.namespace).List(meta.ListOptions{
+		LabelSelector: LabelGroup + "=" + d.group,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error list leases of group %s / %w", d.group, err)
+	}
+
+	now := time.Now()
+	members := []string{}
+	for _, lease := range leases.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+		if now.After(expiry) {
+			continue
+		}
+		members = append(members, *spec.HolderIdentity)
+	}
+
+	return members, nil
+}
+
+// renew create or renew the Lease of the member
+func (d *leaseDiscovery) renew(ctx context.Context) error {
+	leases := d.client.CoordinationV1().Leases(d.namespace)
+	now := meta.NewMicroTime(time.Now())
+	seconds := int32(d.duration.Seconds())
+
+	lease, err := leases.Get(d.name(), meta.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err = leases.Create(&coordination.Lease{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      d.name(),
+				Namespace: d.namespace,
+				Labels:    map[string]string{LabelGroup: d.group},
+			},
+			Spec: coordination.LeaseSpec{
+				HolderIdentity:       &d.identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error create lease %s / %w", d.name(), err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error get lease %s / %w", d.name(), err)
+	}
+
+	lease.Spec.HolderIdentity = &d.identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	if _, err := leases.Update(lease); err != nil {
+		return fmt.Errorf("error renew lease %s / %w", d.name(), err)
+	}
+
+	return nil
+}
+
+func (d *leaseDiscovery) leave(ctx context.Context) error {
+	err := d.client.CoordinationV1().Leases(d.namespace).Delete(d.name(), &meta.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("error delete lease %s / %w", d.name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package shard
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// ring is a consistent hash ring of members, with virtual nodes
+// spreading keys evenly
+type ring struct {
+	hashes  []uint32
+	members map[uint32]string
+}
+
+func newRing(members []string, vnodes int) *ring {
+	r := &ring{
+		hashes:  make([]uint32, 0, len(members)*vnodes),
+		members: make(map[uint32]string, len(members)*vnodes),
+	}
+	for _, m := range members {
+		for i := 0; i < vnodes; i++ {
+			h := hash(m + "#" + strconv.Itoa(i))
+			if _, ok := r.members[h]; ok {
+				continue
+			}
+			r.members[h] = m
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+
+	return r
+}
+
+// get the member owning a key, empty if the ring has no member
+func (r *ring) get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= h
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.members[r.hashes[i]]
+}
+
+// hash spread similar keys, e.g. virtual nodes of a member, over the ring
+func hash(s string) uint32 {
+	sum := md5.Sum([]byte(s))
+	return binary.BigEndian.Uint32(sum[:4])
+}
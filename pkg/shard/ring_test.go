@@ -0,0 +1,78 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func keys(n int) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("payments/api-%d", i)
+	}
+	return list
+}
+
+func TestRingPlacement(t *testing.T) {
+	if got := newRing(nil, 16).get("api"); got != "" {
+		t.Errorf("got owner %q of an empty ring", got)
+	}
+
+	members := []string{"replica-0", "replica-1", "replica-2"}
+	r := newRing(members, defaultVirtualNodes)
+	again := newRing([]string{"replica-2", "replica-0", "replica-1"}, defaultVirtualNodes)
+
+	owned := map[string]int{}
+	list := keys(3000)
+	for _, key := range list {
+		owner := r.get(key)
+		if owner != again.get(key) {
+			t.Fatalf("got owners %s and %s of %s, depending on members order", owner, again.get(key), key)
+		}
+		owned[owner]++
+	}
+
+	for _, m := range members {
+		// virtual nodes spread keys evenly, 1000 each
+		if owned[m] < 700 || owned[m] > 1300 {
+			t.Errorf("got %d keys owned by %s of %d", owned[m], m, len(list))
+		}
+	}
+	if len(owned) != len(members) {
+		t.Errorf("got owners %v, want %v", owned, members)
+	}
+}
+
+func TestRingRebalance(t *testing.T) {
+	before := newRing([]string{"replica-0", "replica-1", "replica-2"}, defaultVirtualNodes)
+	after := newRing([]string{"replica-0", "replica-1", "replica-2", "replica-3"}, defaultVirtualNodes)
+
+	moved := 0
+	list := keys(3000)
+	for _, key := range list {
+		from, to := before.get(key), after.get(key)
+		if from == to {
+			continue
+		}
+		// keys only move to the joining member
+		if to != "replica-3" {
+			t.Errorf("got %s moved from %s to %s", key, from, to)
+		}
+		moved++
+	}
+	if moved < 500 || moved > 1000 {
+		t.Errorf("got %d keys moved of %d, want about a quarter", moved, len(list))
+	}
+
+	// only keys of a leaving member move
+	left := newRing([]string{"replica-0", "replica-2"}, defaultVirtualNodes)
+	for _, key := range list {
+		from, to := before.get(key), left.get(key)
+		if from != to && from != "replica-1" {
+			t.Errorf("got %s moved from %s to %s", key, from, to)
+		}
+		if to == "replica-1" {
+			t.Errorf("got %s owned by the left member", key)
+		}
+	}
+}
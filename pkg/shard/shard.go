@@ -0,0 +1,292 @@
+// Package shard split the nodes of a topology across collector replicas
+// with consistent hashing, so each replica only expands its share.
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danztran/telescope/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var defaultLogger = utils.MustGetLogger("shard")
+
+// discoveries of shard members
+const (
+	DiscoveryStatic string = "static"
+	DiscoveryDNS    string = "dns"
+	DiscoveryLease  string = "lease"
+)
+
+const (
+	defaultGroup           = "telescope"
+	defaultVirtualNodes    = 128
+	defaultRefreshInterval = 30 * time.Second
+	defaultLeaseDuration   = 90 * time.Second
+)
+
+type Deps struct {
+	Log *zap.SugaredLogger
+	// Client of the Lease API, required by the lease discovery
+	Client kubernetes.Interface
+	Config Config
+}
+
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Discovery of members: static, dns or lease
+	Discovery string `mapstructure:"discovery"`
+	// Identity of the replica, POD_IP for the dns discovery,
+	// else POD_NAME or the hostname if empty
+	Identity string `mapstructure:"identity"`
+	// Members are the identities of replicas of the static discovery
+	Members []string `mapstructure:"members"`
+	// Service is the headless Service resolved by the dns discovery,
+	// e.g. telescope-headless.monitoring.svc.cluster.local
+	Service string `mapstructure:"service"`
+	// Group name the Leases of the lease discovery
+	Group     string `mapstructure:"group"`
+	Namespace string `mapstructure:"namespace"`
+	// LeaseDuration is the time members are kept without renewing their Lease
+	LeaseDuration   time.Duration `mapstructure:"lease_duration"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	// VirtualNodes is the number of ring points per member
+	VirtualNodes int `mapstructure:"virtual_nodes"`
+}
+
+type Sharder interface {
+	// Owns check if a key is owned by the replica
+	Owns(key string) bool
+	// Generation change each time the members change
+	Generation() uint64
+	Members() []string
+	Run(ctx context.Context)
+}
+
+type sharder struct {
+	config    Config
+	log       *zap.SugaredLogger
+	discovery discovery
+	members   *prometheus.GaugeVec
+	rebalance prometheus.Counter
+
+	mx         sync.RWMutex
+	ring       *ring
+	list       []string
+	generation uint64
+}
+
+func MustNew(deps Deps) Sharder {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func New(deps Deps) (Sharder, error) {
+	config := deps.Config
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	if config.Discovery == "" {
+		config.Discovery = DiscoveryStatic
+	}
+	if config.Identity == "" && config.Discovery == DiscoveryDNS {
+		config.Identity = os.Getenv("POD_IP")
+	}
+	if config.Identity == "" {
+		config.Identity = os.Getenv("POD_NAME")
+	}
+	if config.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error get hostname / %w", err)
+		}
+		config.Identity = hostname
+	}
+	if config.Group == "" {
+		config.Group = defaultGroup
+	}
+	if config.Namespace == "" {
+		config.Namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if config.Namespace == "" {
+		config.Namespace = meta.NamespaceDefault
+	}
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = defaultLeaseDuration
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultRefreshInterval
+	}
+	if config.VirtualNodes <= 0 {
+		config.VirtualNodes = defaultVirtualNodes
+	}
+
+	var d discovery
+	switch config.Discovery {
+	case DiscoveryStatic:
+		found := false
+		for _, m := range config.Members {
+			found = found || m == config.Identity
+		}
+		if !found {
+			return nil, fmt.Errorf("identity %s is not a member of the static discovery", config.Identity)
+		}
+		d = &staticDiscovery{list: config.Members}
+	case DiscoveryDNS:
+		if config.Service == "" {
+			return nil, fmt.Errorf("service is required by the dns discovery")
+		}
+		d = &dnsDiscovery{service: config.Service, resolver: net.DefaultResolver}
+	case DiscoveryLease:
+		if deps.Client == nil {
+			return nil, fmt.Errorf("kube client is required by the lease discovery")
+		}
+		d = &leaseDiscovery{
+			client:    deps.Client,
+			namespace: config.Namespace,
+			group:     config.Group,
+			identity:  config.Identity,
+			duration:  config.LeaseDuration,
+		}
+	default:
+		return nil, fmt.Errorf("invalid discovery: %s", config.Discovery)
+	}
+
+	members := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "shard_members",
+		Help: "Members of the shard group seen by the replica.",
+	}, []string{"group"})
+	rebalance := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "shard_rebalances_total",
+		Help: "Changes of the shard group members.",
+	})
+	if err := prometheus.Register(members); err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		members = are.ExistingCollector.(*prometheus.GaugeVec)
+	}
+	if err := prometheus.Register(rebalance); err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		rebalance = are.ExistingCollector.(prometheus.Counter)
+	}
+
+	s := &sharder{
+		config:    config,
+		log:       deps.Log,
+		discovery: d,
+		members:   members,
+		rebalance: rebalance,
+	}
+	// own everything alone until members are discovered
+	s.set([]string{config.Identity})
+
+	if err := s.refresh(context.Background()); err != nil {
+		s.log.Warn(err)
+	}
+
+	return s, nil
+}
+
+// Run refresh members every refresh interval, and leave the group when done
+func (s *sharder) Run(ctx context.Context) {
+	utils.RunStateful(ctx, s.config.RefreshInterval, func() {
+		if err := s.refresh(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error(err)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.discovery.leave(ctx); err != nil {
+		s.log.Error(err)
+	}
+}
+
+// refresh discover members and rebuild the ring if they changed
+func (s *sharder) refresh(ctx context.Context) error {
+	list, err := s.discovery.members(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, m := range list {
+		if m == s.config.Identity {
+			found = true
+			break
+		}
+	}
+	if !found {
+		// a replica not discovered yet still collects its share,
+		// as others will once they see it
+		list = append(list, s.config.Identity)
+	}
+
+	s.set(list)
+	return nil
+}
+
+func (s *sharder) set(list []string) {
+	seen := map[string]bool{}
+	members := []string{}
+	for _, m := range list {
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		members = append(members, m)
+	}
+	sort.Strings(members)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if strings.Join(members, ",") == strings.Join(s.list, ",") {
+		return
+	}
+	if s.ring != nil {
+		s.rebalance.Inc()
+		s.log.Infof("rebalanced shard group %s: %v", s.config.Group, members)
+	}
+	s.ring = newRing(members, s.config.VirtualNodes)
+	s.list = members
+	s.generation++
+	s.members.WithLabelValues(s.config.Group).Set(float64(len(members)))
+}
+
+func (s *sharder) Owns(key string) bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.ring.get(key) == s.config.Identity
+}
+
+func (s *sharder) Generation() uint64 {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.generation
+}
+
+func (s *sharder) Members() []string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return append([]string{}, s.list...)
+}
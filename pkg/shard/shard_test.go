@@ -0,0 +1,214 @@
+package shard
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// members is a discovery of members changed by the test
+type members struct {
+	mx   sync.Mutex
+	list []string
+}
+
+func (d *members) set(list ...string) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.list = list
+}
+
+func (d *members) members(ctx context.Context) ([]string, error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.list, nil
+}
+
+func (d *members) leave(ctx context.Context) error {
+	return nil
+}
+
+func newTestSharder(t *testing.T, identity string, d *members) *sharder {
+	t.Helper()
+	s, err := New(Deps{
+		Config: Config{
+			Enabled:  true,
+			Identity: identity,
+			Members:  []string{identity},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh := s.(*sharder)
+	sh.discovery = d
+	if err := sh.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return sh
+}
+
+func TestNewStaticDiscovery(t *testing.T) {
+	_, err := New(Deps{Config: Config{Identity: "replica-3", Members: []string{"replica-0", "replica-1"}}})
+	if err == nil {
+		t.Error("got no error of an identity missing from static members")
+	}
+
+	s, err := New(Deps{Config: Config{Identity: "replica-1", Members: []string{"replica-1", "replica-0", "replica-1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Members(), []string{"replica-0", "replica-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	d := &members{}
+	d.set("replica-0", "replica-1")
+	replicas := []*sharder{
+		newTestSharder(t, "replica-0", d),
+		newTestSharder(t, "replica-1", d),
+		newTestSharder(t, "replica-2", d),
+	}
+
+	// a replica not discovered yet still owns its share
+	if got, want := replicas[2].Members(), []string{"replica-0", "replica-1", "replica-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+
+	owners := func(replicas []*sharder) map[string]string {
+		owned := map[string]string{}
+		for _, key := range keys(1000) {
+			for _, r := range replicas {
+				if !r.Owns(key) {
+					continue
+				}
+				if owner, ok := owned[key]; ok {
+					t.Errorf("got %s owned by %s and %s", key, owner, r.config.Identity)
+				}
+				owned[key] = r.config.Identity
+			}
+			if _, ok := owned[key]; !ok {
+				t.Errorf("got %s owned by no replica", key)
+			}
+		}
+		return owned
+	}
+	before := owners(replicas[:2])
+
+	// members change once discovered
+	d.set("replica-0", "replica-1", "replica-2")
+	generation := replicas[0].Generation()
+	for _, r := range replicas {
+		if err := r.refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if replicas[0].Generation() != generation+1 {
+		t.Errorf("got generation %d, want %d", replicas[0].Generation(), generation+1)
+	}
+	after := owners(replicas)
+	for key, owner := range after {
+		if owner != before[key] && owner != "replica-2" {
+			t.Errorf("got %s moved from %s to %s", key, before[key], owner)
+		}
+	}
+
+	// same members keep the generation
+	generation = replicas[0].Generation()
+	d.set("replica-2", "replica-1", "replica-0")
+	if err := replicas[0].refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if replicas[0].Generation() != generation {
+		t.Errorf("got generation %d after refreshing the same members, want %d", replicas[0].Generation(), generation)
+	}
+}
+
+func TestLeaseDiscovery(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	discover := func(identity string) *leaseDiscovery {
+		return &leaseDiscovery{
+			client:    client,
+			namespace: "monitoring",
+			group:     "telescope",
+			identity:  identity,
+			duration:  time.Minute,
+		}
+	}
+	a, b := discover("replica-a"), discover("replica-b")
+	ctx := context.Background()
+
+	if _, err := a.members(ctx); err != nil {
+		t.Fatal(err)
+	}
+	list, err := b.members(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(list)
+	if want := []string{"replica-a", "replica-b"}; !reflect.DeepEqual(list, want) {
+		t.Errorf("got members %v, want %v", list, want)
+	}
+
+	// renewing keeps a single lease per member
+	if _, err := a.members(ctx); err != nil {
+		t.Fatal(err)
+	}
+	leases, err := client.CoordinationV1().Leases("monitoring").List(meta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases.Items) != 2 {
+		t.Errorf("got %d leases, want 2", len(leases.Items))
+	}
+
+	// members not renewing their lease expire
+	lease, err := client.CoordinationV1().Leases("monitoring").Get(b.name(), meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed := meta.NewMicroTime(time.Now().Add(-2 * time.Minute))
+	lease.Spec.RenewTime = &renewed
+	if _, err := client.CoordinationV1().Leases("monitoring").Update(lease); err != nil {
+		t.Fatal(err)
+	}
+	list, err = a.members(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"replica-a"}; !reflect.DeepEqual(list, want) {
+		t.Errorf("got members %v, want %v", list, want)
+	}
+
+	// leases of other groups are ignored, and members leave on shutdown
+	other := discover("replica-c")
+	other.group = "other"
+	if _, err := other.members(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.leave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoordinationV1().Leases("monitoring").Get(b.name(), meta.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("got lease of a left member, error %v", err)
+	}
+	if err := b.leave(ctx); err != nil {
+		t.Errorf("got error leaving twice / %s", err)
+	}
+	list, err = a.members(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"replica-a"}; !reflect.DeepEqual(list, want) {
+		t.Errorf("got members %v, want %v", list, want)
+	}
+}
@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/utils"
+	"go.uber.org/zap"
+
+	// pure-Go sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS edges (
+	id         INTEGER PRIMARY KEY,
+	cluster    TEXT NOT NULL,
+	src        TEXT NOT NULL,
+	src_ns     TEXT NOT NULL,
+	dest       TEXT NOT NULL,
+	dest_ns    TEXT NOT NULL,
+	dest_port  TEXT NOT NULL,
+	first_seen INTEGER NOT NULL,
+	last_seen  INTEGER NOT NULL,
+	count      INTEGER NOT NULL,
+	UNIQUE (cluster, src, src_ns, dest, dest_ns, dest_port)
+);
+CREATE INDEX IF NOT EXISTS edges_last_seen ON edges (last_seen);
+
+-- days an edge was seen on, as days since unix epoch
+CREATE TABLE IF NOT EXISTS edge_days (
+	edge_id INTEGER NOT NULL REFERENCES edges (id) ON DELETE CASCADE,
+	day     INTEGER NOT NULL,
+	count   INTEGER NOT NULL,
+	PRIMARY KEY (edge_id, day)
+);
+CREATE INDEX IF NOT EXISTS edge_days_day ON edge_days (day);
+`
+
+const sqliteUpsertEdge = `
+INSERT INTO edges (cluster, src, src_ns, dest, dest_ns, dest_port, first_seen, last_seen, count)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1)
+ON CONFLICT (cluster, src, src_ns, dest, dest_ns, dest_port) DO UPDATE SET
+	first_seen = min(first_seen, excluded.first_seen),
+	last_seen = max(last_seen, excluded.last_seen),
+	count = count + 1
+RETURNING id
+`
+
+const sqliteUpsertDay = `
+INSERT INTO edge_days (edge_id, day, count) VALUES (?, ?, 1)
+ON CONFLICT (edge_id, day) DO UPDATE SET count = count + 1
+`
+
+const sqliteSelectEdges = `
+SELECT cluster, src, src_ns, dest, dest_ns, dest_port, first_seen, last_seen, count
+FROM edges e
+WHERE last_seen >= ? AND first_seen <= ?
+	AND EXISTS (SELECT 1 FROM edge_days d WHERE d.edge_id = e.id AND d.day BETWEEN ? AND ?)
+ORDER BY cluster, src_ns, src, dest_ns, dest, dest_port
+`
+
+type sqlite struct {
+	config    Config
+	log       *zap.SugaredLogger
+	collector collector.Collector
+	db        *sql.DB
+}
+
+func newSQLite(log *zap.SugaredLogger, c collector.Collector, config Config) (*sqlite, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("error create storage directory / %w", err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=%s&_pragma=%s&_pragma=%s",
+		config.Path,
+		url.QueryEscape("foreign_keys(1)"),
+		url.QueryEscape("journal_mode(WAL)"),
+		url.QueryEscape("busy_timeout(5000)"),
+	)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error open sqlite %s / %w", config.Path, err)
+	}
+	// a single writer, sqlite serializing writes anyway
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrate sqlite %s / %w", config.Path, err)
+	}
+
+	s := &sqlite{
+		config:    config,
+		log:       log,
+		collector: c,
+		db:        db,
+	}
+
+	return s, nil
+}
+
+// Run record edges of the collector in interval, and prune edges
+// out of retention, until the context is done
+func (s *sqlite) Run(ctx context.Context) {
+	if s.collector == nil {
+		s.log.Info("disabled recording edges: no collector")
+		return
+	}
+
+	// since is the last record time, edges exposed before were recorded already
+	var since time.Time
+	utils.RunStateless(ctx, s.config.RecordInterval, func() {
+		now := time.Now()
+		edges := fromCollector(s.config.Cluster, s.collector.GetEdges(), since)
+		if err := s.RecordEdges(ctx, edges, now); err != nil {
+			s.log.Error(err)
+			return
+		}
+		since = now
+		if s.config.Retention <= 0 {
+			return
+		}
+		if _, err := s.Prune(ctx, now.Add(-s.config.Retention)); err != nil {
+			s.log.Error(err)
+		}
+	})
+}
+
+// RecordEdges upsert edges seen at a time: update first & last seen,
+// and count the edges once per record
+func (s *sqlite) RecordEdges(ctx context.Context, edges []Edge, seenAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error begin transaction / %w", err)
+	}
+	defer tx.Rollback()
+
+	seen := seenAt.Unix()
+	day := unixDay(seenAt)
+	recorded := map[Edge]bool{}
+	for _, e := range edges {
+		key := Edge{
+			Cluster:              e.Cluster,
+			Source:               e.Source,
+			SourceNamespace:      e.SourceNamespace,
+			Destination:          e.Destination,
+			DestinationNamespace: e.DestinationNamespace,
+			DestinationPort:      e.DestinationPort,
+		}
+		if recorded[key] {
+			continue
+		}
+		recorded[key] = true
+
+		var id int64
+		err := tx.QueryRowContext(ctx, sqliteUpsertEdge,
+			e.Cluster, e.Source, e.SourceNamespace, e.Destination, e.DestinationNamespace, e.DestinationPort,
+			seen, seen,
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("error record edge %+v / %w", key, err)
+		}
+		if _, err := tx.ExecContext(ctx, sqliteUpsertDay, id, day); err != nil {
+			return fmt.Errorf("error record edge day %+v / %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error commit edges / %w", err)
+	}
+
+	s.log.Debugf("recorded edges: %d", len(recorded))
+
+	return nil
+}
+
+// GetEdges get edges seen between start & end, to the day
+func (s *sqlite) GetEdges(ctx context.Context, start time.Time, end time.Time) ([]Edge, error) {
+	rows, err := s.db.QueryContext(ctx, sqliteSelectEdges,
+		start.Unix(), end.Unix(),
+		unixDay(start), unixDay(end),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error query edges / %w", err)
+	}
+	defer rows.Close()
+
+	edges := []Edge{}
+	for rows.Next() {
+		var e Edge
+		var firstSeen, lastSeen int64
+		err := rows.Scan(
+			&e.Cluster, &e.Source, &e.SourceNamespace, &e.Destination, &e.DestinationNamespace, &e.DestinationPort,
+			&firstSeen, &lastSeen, &e.Count,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scan edge / %w", err)
+		}
+		e.FirstSeen = time.Unix(firstSeen, 0)
+		e.LastSeen = time.Unix(lastSeen, 0)
+		edges = append(edges, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error query edges / %w", err)
+	}
+
+	return edges, nil
+}
+
+// GetConnections get edges seen between start & end as mapnode connections
+func (s *sqlite) GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error) {
+	defer utils.LogDuration()(s.log, "GetConnections with [start:%v] [end:%v]", start, end)
+
+	edges, err := s.GetEdges(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConnections(edges), nil
+}
+
+// Prune delete edges last seen before a time, and their older days
+func (s *sqlite) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE last_seen < ?`, before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("error prune edges / %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM edge_days WHERE day < ?`, unixDay(before)); err != nil {
+		return 0, fmt.Errorf("error prune edge days / %w", err)
+	}
+
+	pruned, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if pruned > 0 {
+		s.log.Infof("pruned edges: %d", pruned)
+	}
+
+	return pruned, nil
+}
+
+func (s *sqlite) Close() error {
+	return s.db.Close()
+}
+
+// unixDay get the number of days since unix epoch
+func unixDay(t time.Time) int64 {
+	return t.Unix() / int64(24*time.Hour/time.Second)
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	apiToDB  = Edge{Cluster: "prod", Source: "api", SourceNamespace: "payments", Destination: "db", DestinationNamespace: "payments", DestinationPort: "5432"}
+	webToAPI = Edge{Cluster: "prod", Source: "web", SourceNamespace: "payments", Destination: "api", DestinationNamespace: "payments", DestinationPort: "80"}
+)
+
+func newTestSQLite(t *testing.T) *sqlite {
+	t.Helper()
+	s, err := newSQLite(defaultLogger, nil, Config{Path: filepath.Join(t.TempDir(), "telescope.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// day get the time of an hour of a day of january 2026
+func day(d int, hour int) time.Time {
+	return time.Date(2026, 1, d, hour, 0, 0, 0, time.UTC)
+}
+
+func (s *sqlite) countDays(t *testing.T, e Edge) map[int64]int64 {
+	t.Helper()
+	rows, err := s.db.Query(`
+		SELECT d.day, d.count FROM edge_days d JOIN edges e ON e.id = d.edge_id
+		WHERE e.src = ? AND e.dest = ?`, e.Source, e.Destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	days := map[int64]int64{}
+	for rows.Next() {
+		var d, count int64
+		if err := rows.Scan(&d, &count); err != nil {
+			t.Fatal(err)
+		}
+		days[d] = count
+	}
+	return days
+}
+
+func (s *sqlite) countRows(t *testing.T, table string) int {
+	t.Helper()
+	var count int
+	if err := s.db.QueryRow(`SELECT count(*) FROM ` + table).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func keysOf(edges []Edge) []Edge {
+	keys := make([]Edge, len(edges))
+	for i, e := range edges {
+		keys[i] = Edge{
+			Cluster:              e.Cluster,
+			Source:               e.Source,
+			SourceNamespace:      e.SourceNamespace,
+			Destination:          e.Destination,
+			DestinationNamespace: e.DestinationNamespace,
+			DestinationPort:      e.DestinationPort,
+		}
+	}
+	return keys
+}
+
+// record api->db on the 10th & twice on the 12th, recording the 12th first,
+// and web->api on the 12th
+func recordTestEdges(t *testing.T, s *sqlite) {
+	t.Helper()
+	ctx := context.Background()
+	records := []struct {
+		edges  []Edge
+		seenAt time.Time
+	}{
+		{edges: []Edge{apiToDB, webToAPI, apiToDB}, seenAt: day(12, 12)},
+		{edges: []Edge{apiToDB}, seenAt: day(10, 12)},
+		{edges: []Edge{apiToDB}, seenAt: day(12, 14)},
+	}
+	for _, r := range records {
+		if err := s.RecordEdges(ctx, r.edges, r.seenAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecordEdges(t *testing.T) {
+	s := newTestSQLite(t)
+	recordTestEdges(t, s)
+
+	edges, err := s.GetEdges(context.Background(), day(1, 0), day(31, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 2 {
+		t.Fatalf("got edges %+v, want 2", edges)
+	}
+
+	// first & last seen are kept whatever the record order, and edges
+	// are counted once per record
+	got := map[string]Edge{}
+	for _, e := range edges {
+		got[e.Source] = e
+	}
+	for _, want := range []struct {
+		edge      Edge
+		firstSeen time.Time
+		lastSeen  time.Time
+		count     int64
+	}{
+		{edge: apiToDB, firstSeen: day(10, 12), lastSeen: day(12, 14), count: 3},
+		{edge: webToAPI, firstSeen: day(12, 12), lastSeen: day(12, 12), count: 1},
+	} {
+		e := got[want.edge.Source]
+		if !e.FirstSeen.Equal(want.firstSeen) || !e.LastSeen.Equal(want.lastSeen) || e.Count != want.count {
+			t.Errorf("got %s seen %v to %v %d times, want %v to %v %d times", e.Source,
+				e.FirstSeen.UTC(), e.LastSeen.UTC(), e.Count, want.firstSeen, want.lastSeen, want.count)
+		}
+	}
+
+	days := s.countDays(t, apiToDB)
+	want := map[int64]int64{unixDay(day(10, 0)): 1, unixDay(day(12, 0)): 2}
+	if len(days) != len(want) {
+		t.Errorf("got days %v of api->db, want %v", days, want)
+	}
+	for d, count := range want {
+		if days[d] != count {
+			t.Errorf("got api->db seen %d times on day %d, want %d", days[d], d, count)
+		}
+	}
+}
+
+func TestGetEdgesWindow(t *testing.T) {
+	s := newTestSQLite(t)
+	recordTestEdges(t, s)
+
+	tests := []struct {
+		name  string
+		start time.Time
+		end   time.Time
+		want  []Edge
+	}{
+		{name: "all days", start: day(10, 0), end: day(12, 23), want: []Edge{apiToDB, webToAPI}},
+		{name: "first day", start: day(10, 0), end: day(10, 23), want: []Edge{apiToDB}},
+		// api->db spans the day between its records, without being seen on it
+		{name: "unseen day", start: day(11, 0), end: day(11, 23)},
+		{name: "last day", start: day(12, 0), end: day(12, 23), want: []Edge{apiToDB, webToAPI}},
+		// web->api is last seen before the start, within the same day
+		{name: "last seen before", start: day(12, 13), end: day(12, 23), want: []Edge{apiToDB}},
+		{name: "before", start: day(1, 0), end: day(9, 23)},
+		{name: "after", start: day(13, 0), end: day(20, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edges, err := s.GetEdges(context.Background(), tt.start, tt.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := keysOf(edges)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got edges %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got edge %+v, want %+v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := newTestSQLite(t)
+	recordTestEdges(t, s)
+	ctx := context.Background()
+
+	// edges seen since are kept, with their days since
+	pruned, err := s.Prune(ctx, day(11, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 0 {
+		t.Errorf("got %d pruned edges, want 0", pruned)
+	}
+	if days := s.countDays(t, apiToDB); len(days) != 1 || days[unixDay(day(12, 0))] != 2 {
+		t.Errorf("got days %v of api->db, want the 12th only", days)
+	}
+	edges, err := s.GetEdges(ctx, day(10, 0), day(10, 23))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 0 {
+		t.Errorf("got edges %+v on a pruned day", edges)
+	}
+
+	// edges last seen before are deleted, cascading their days
+	// of the same day as the prune time
+	pruned, err = s.Prune(ctx, day(12, 13))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("got %d pruned edges, want 1", pruned)
+	}
+	if days := s.countDays(t, webToAPI); len(days) != 0 {
+		t.Errorf("got days %v of a pruned edge", days)
+	}
+	if got := s.countRows(t, "edge_days"); got != 1 {
+		t.Errorf("got %d edge days, want 1", got)
+	}
+
+	pruned, err = s.Prune(ctx, day(12, 15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 || s.countRows(t, "edges") != 0 || s.countRows(t, "edge_days") != 0 {
+		t.Errorf("got %d pruned edges, %d edges & %d days left, want all pruned",
+			pruned, s.countRows(t, "edges"), s.countRows(t, "edge_days"))
+	}
+}
@@ -0,0 +1,125 @@
+// Package storage persist observed edges, with first & last seen times,
+// so that the mesh graph does not depend on Prometheus retention.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+	"github.com/danztran/telescope/pkg/mapnode"
+	"github.com/danztran/telescope/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var defaultLogger = utils.MustGetLogger("storage")
+
+// drivers of storage
+const (
+	DriverSQLite string = "sqlite"
+)
+
+type Deps struct {
+	Log       *zap.SugaredLogger
+	Collector collector.Collector
+	Config    Config
+}
+
+type Config struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Driver  string `mapstructure:"driver"`
+	// Path of the database file
+	Path string `mapstructure:"path"`
+	// Cluster name of the recorded collector edges, if multi-cluster
+	Cluster        string        `mapstructure:"cluster"`
+	RecordInterval time.Duration `mapstructure:"record_interval"`
+	// Retention of edges since last seen, forever if not set
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// Edge is a persisted connection from a source to a destination port
+type Edge struct {
+	Cluster              string    `json:"cluster,omitempty"`
+	Source               string    `json:"src"`
+	SourceNamespace      string    `json:"src_ns"`
+	Destination          string    `json:"dest"`
+	DestinationNamespace string    `json:"dest_ns"`
+	DestinationPort      string    `json:"dest_port"`
+	FirstSeen            time.Time `json:"first_seen"`
+	LastSeen             time.Time `json:"last_seen"`
+	// Count is the number of times the edge was recorded
+	Count int64 `json:"count"`
+}
+
+// Storage persist edges, and get them back as mapnode connections
+type Storage interface {
+	RecordEdges(ctx context.Context, edges []Edge, seenAt time.Time) error
+	GetEdges(ctx context.Context, start time.Time, end time.Time) ([]Edge, error)
+	GetConnections(ctx context.Context, start time.Time, end time.Time) ([]mapnode.Connection, error)
+	Prune(ctx context.Context, before time.Time) (int64, error)
+	Run(ctx context.Context)
+	Close() error
+}
+
+func MustNew(deps Deps) Storage {
+	c, err := New(deps)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// New open the storage of the configured driver
+func New(deps Deps) (Storage, error) {
+	if deps.Log == nil {
+		deps.Log = defaultLogger
+	}
+	config := deps.Config
+	if config.RecordInterval <= 0 {
+		config.RecordInterval = time.Minute
+	}
+
+	switch config.Driver {
+	case DriverSQLite, "":
+		return newSQLite(deps.Log, deps.Collector, config)
+	}
+
+	return nil, fmt.Errorf("unknown storage driver: %s", config.Driver)
+}
+
+// toConnections convert edges to mapnode connections
+func toConnections(edges []Edge) []mapnode.Connection {
+	connections := make([]mapnode.Connection, len(edges))
+	for i, e := range edges {
+		connections[i] = mapnode.Connection{
+			Cluster:              e.Cluster,
+			Source:               e.Source,
+			SourceNamespace:      e.SourceNamespace,
+			Destination:          e.Destination,
+			DestinationNamespace: e.DestinationNamespace,
+			DestinationPort:      e.DestinationPort,
+		}
+	}
+	return connections
+}
+
+// fromCollector convert collector edges seen since a time to edges of a cluster,
+// the exposed edges being kept until a collector reset
+func fromCollector(cluster string, exposed []collector.Edge, since time.Time) []Edge {
+	edges := make([]Edge, 0, len(exposed))
+	for _, e := range exposed {
+		if e.SeenAt.Before(since) {
+			continue
+		}
+		edges = append(edges, Edge{
+			Cluster:              cluster,
+			Source:               e.Source,
+			SourceNamespace:      e.SourceNamespace,
+			Destination:          e.Destination,
+			DestinationNamespace: e.DestinationNamespace,
+			DestinationPort:      e.DestinationPort,
+		})
+	}
+	return edges
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danztran/telescope/pkg/collector"
+)
+
+func TestFromCollectorSkipsEdgesSeenBefore(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	exposed := []collector.Edge{
+		{Source: "api", SourceNamespace: "default", Destination: "db", DestinationNamespace: "default", DestinationPort: "5432", SeenAt: since.Add(-time.Minute)},
+		{Source: "web", SourceNamespace: "default", Destination: "api", DestinationNamespace: "default", DestinationPort: "80", SeenAt: since.Add(time.Second)},
+	}
+
+	edges := fromCollector("prod", exposed, since)
+
+	want := Edge{Cluster: "prod", Source: "web", SourceNamespace: "default", Destination: "api", DestinationNamespace: "default", DestinationPort: "80"}
+	if len(edges) != 1 || edges[0] != want {
+		t.Errorf("got edges %+v, want %+v", edges, want)
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GaugeSeries replace the series of a gauge vector by a new set.
+// Series are updated in place and only stale ones are deleted,
+// so that scrapes never see the vector empty while it is replaced.
+type GaugeSeries struct {
+	vec *prometheus.GaugeVec
+
+	mx     sync.Mutex
+	series map[string][]string
+	values map[string]float64
+}
+
+func NewGaugeSeries(vec *prometheus.GaugeVec) *GaugeSeries {
+	return &GaugeSeries{
+		vec:    vec,
+		series: make(map[string][]string),
+		values: make(map[string]float64),
+	}
+}
+
+// Add add a value to the series of label values in the new set
+func (g *GaugeSeries) Add(value float64, labelValues ...string) {
+	g.put(value, true, labelValues)
+}
+
+// Set set the value of the series of label values in the new set
+func (g *GaugeSeries) Set(value float64, labelValues ...string) {
+	g.put(value, false, labelValues)
+}
+
+func (g *GaugeSeries) put(value float64, add bool, labelValues []string) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	if add {
+		value += g.values[key]
+	}
+	g.values[key] = value
+	if _, ok := g.series[key]; !ok {
+		g.series[key] = labelValues
+	}
+}
+
+// Commit expose the new set, deleting the series which are not in it,
+// and start a next set
+func (g *GaugeSeries) Commit() {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	for key, labelValues := range g.series {
+		value, ok := g.values[key]
+		if !ok {
+			g.vec.DeleteLabelValues(labelValues...)
+			delete(g.series, key)
+			continue
+		}
+		g.vec.WithLabelValues(labelValues...).Set(value)
+	}
+
+	g.values = make(map[string]float64)
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGaugeSeries(t *testing.T) {
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test"}, []string{"edge"})
+	g := NewGaugeSeries(vec)
+
+	g.Set(1, "a")
+	g.Add(1, "b")
+	g.Add(1, "b")
+	g.Commit()
+
+	if got := testutil.ToFloat64(vec.WithLabelValues("a")); got != 1 {
+		t.Errorf("got a %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(vec.WithLabelValues("b")); got != 2 {
+		t.Errorf("got b %v, want 2", got)
+	}
+
+	// a is kept without being reset, b is deleted
+	g.Set(1, "a")
+	g.Set(1, "c")
+	g.Commit()
+
+	if vec.DeleteLabelValues("b") {
+		t.Error("got stale series b")
+	}
+	for _, edge := range []string{"a", "c"} {
+		if !vec.DeleteLabelValues(edge) {
+			t.Errorf("got no series %s", edge)
+		}
+	}
+}
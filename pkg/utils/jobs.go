@@ -17,28 +17,23 @@ func RunJobsWithContext(ctx context.Context, wg *sync.WaitGroup, jobs ...func(co
 	}
 }
 
-// RunStateless run an interval jobs without waiting for it to complete each time
+// RunStateless run an interval jobs without waiting for it to complete each time.
+// Runs are skipped while the previous one is still running.
 func RunStateless(ctx context.Context, interval time.Duration, job func()) {
-	ticker := time.NewTicker(interval)
-	done := ctx.Done()
-	for {
-		select {
-		case <-ticker.C:
-			go job()
-
-		case <-done:
-			return
-		}
-	}
+	s := Schedule{Interval: interval}
+	s.Mode = ScheduleFixedRate
+	_ = RunSchedule(ctx, s, func(context.Context) error {
+		job()
+		return nil
+	})
 }
 
-// RunStateless run an interval jobs and waiting for it to complete each time
+// RunStateful run an interval jobs and waiting for it to complete each time
 func RunStateful(ctx context.Context, dur time.Duration, job func()) {
-	for {
+	s := Schedule{Interval: dur, Immediate: true}
+	s.Mode = ScheduleFixedDelay
+	_ = RunSchedule(ctx, s, func(context.Context) error {
 		job()
-		if ctx.Err() != nil {
-			break
-		}
-		time.Sleep(dur)
-	}
+		return nil
+	})
 }
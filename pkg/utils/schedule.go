@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// schedule modes
+const (
+	// ScheduleFixedDelay wait the interval after each run completes
+	ScheduleFixedDelay string = "fixed_delay"
+	// ScheduleFixedRate start runs every interval, skipping runs
+	// while the previous one is still running
+	ScheduleFixedRate string = "fixed_rate"
+)
+
+// run statuses of scheduled jobs
+const (
+	RunOK      string = "ok"
+	RunError   string = "error"
+	RunTimeout string = "timeout"
+)
+
+// ScheduleConfig is the configurable part of a schedule
+type ScheduleConfig struct {
+	// Mode is ScheduleFixedDelay or ScheduleFixedRate
+	Mode string `mapstructure:"mode"`
+	// Jitter is the max fraction of the interval randomly added to each wait
+	Jitter float64 `mapstructure:"jitter"`
+	// Timeout is the deadline of each run, none if zero
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Schedule define how an interval job is run
+type Schedule struct {
+	ScheduleConfig
+	// Name label metrics of the job, not recorded if empty
+	Name     string
+	Interval time.Duration
+	// Immediate run the job at start instead of after the first interval
+	Immediate bool
+}
+
+// Validate check the schedule mode, interval, jitter & timeout
+func (s Schedule) Validate() error {
+	switch s.Mode {
+	case ScheduleFixedDelay, ScheduleFixedRate, "":
+	default:
+		return fmt.Errorf("invalid schedule mode: %s", s.Mode)
+	}
+	if s.Interval <= 0 {
+		return fmt.Errorf("interval must be positive: %v", s.Interval)
+	}
+	if s.Jitter < 0 || s.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1: %v", s.Jitter)
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative: %v", s.Timeout)
+	}
+	return nil
+}
+
+var (
+	scheduleRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_runs_total",
+		Help: "Runs of scheduled jobs, by status.",
+	}, []string{"job", "status"})
+	scheduleSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_skipped_runs_total",
+		Help: "Runs of scheduled jobs skipped while the previous run is still running.",
+	}, []string{"job"})
+	scheduleDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scheduler_run_duration_seconds",
+		Help:    "Duration of scheduled job runs.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"job"})
+)
+
+func init() {
+	prometheus.MustRegister(scheduleRuns, scheduleSkipped, scheduleDuration)
+}
+
+// RunSchedule run a job on a schedule until the context is done.
+// Each run get a context with the schedule timeout, and RunSchedule
+// returns once the running job, if any, returns.
+func RunSchedule(ctx context.Context, s Schedule, job func(ctx context.Context) error) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	runSchedule(ctx, nil, s, job)
+	return nil
+}
+
+// Reloader broadcast reloads to the schedules it runs
+type Reloader struct {
+	mx       sync.Mutex
+	reloaded chan struct{}
+}
+
+// Reload restart schedules run by the reloader
+func (r *Reloader) Reload() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.reloaded != nil {
+		close(r.reloaded)
+	}
+	r.reloaded = make(chan struct{})
+}
+
+// Reloaded get a channel closed on the next reload
+func (r *Reloader) Reloaded() <-chan struct{} {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.reloaded == nil {
+		r.reloaded = make(chan struct{})
+	}
+	return r.reloaded
+}
+
+// RunSchedule run a job like RunSchedule, with the schedule got by load,
+// until the context is done. The schedule is loaded again on each reload,
+// after the running job returns, and is paused while load returns false.
+// Only the first started schedule runs the job immediately.
+func (r *Reloader) RunSchedule(ctx context.Context, load func() (Schedule, bool), job func(ctx context.Context) error) error {
+	started := false
+	for ctx.Err() == nil {
+		reloaded := r.Reloaded()
+		s, ok := load()
+		if !ok {
+			select {
+			case <-reloaded:
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		s.Immediate = s.Immediate && !started
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		started = true
+		runSchedule(ctx, reloaded, s, job)
+	}
+
+	return nil
+}
+
+// runSchedule run a job on a schedule until the context is done
+// or the stop channel is closed
+func runSchedule(ctx context.Context, stop <-chan struct{}, s Schedule, job func(ctx context.Context) error) {
+	if s.Mode == ScheduleFixedRate {
+		runFixedRate(ctx, stop, s, job)
+	} else {
+		runFixedDelay(ctx, stop, s, job)
+	}
+}
+
+func runFixedDelay(ctx context.Context, stop <-chan struct{}, s Schedule, job func(ctx context.Context) error) {
+	if !s.Immediate && !sleep(ctx, stop, s.wait()) {
+		return
+	}
+	for {
+		s.run(ctx, job)
+		if !sleep(ctx, stop, s.wait()) {
+			return
+		}
+	}
+}
+
+func runFixedRate(ctx context.Context, stop <-chan struct{}, s Schedule, job func(ctx context.Context) error) {
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	running := make(chan struct{}, 1)
+	start := func() {
+		select {
+		case running <- struct{}{}:
+		default:
+			if s.Name != "" {
+				scheduleSkipped.WithLabelValues(s.Name).Inc()
+			}
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-running }()
+			s.run(ctx, job)
+		}()
+	}
+
+	if s.Immediate {
+		start()
+	}
+	for sleep(ctx, stop, s.wait()) {
+		start()
+	}
+}
+
+// run the job with a timeout context, and record its metrics
+func (s Schedule) run(ctx context.Context, job func(ctx context.Context) error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	ts := time.Now()
+	err := job(ctx)
+	if s.Name == "" {
+		return
+	}
+
+	status := RunOK
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status = RunTimeout
+	default:
+		status = RunError
+	}
+	scheduleRuns.WithLabelValues(s.Name, status).Inc()
+	scheduleDuration.WithLabelValues(s.Name).Observe(time.Since(ts).Seconds())
+}
+
+// wait get the interval with a random jitter
+func (s Schedule) wait() time.Duration {
+	if s.Jitter <= 0 {
+		return s.Interval
+	}
+	return s.Interval + time.Duration(rand.Float64()*s.Jitter*float64(s.Interval))
+}
+
+// sleep wait for a duration, returning false if the context is done
+// or the stop channel is closed first
+func sleep(ctx context.Context, stop <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-stop:
+		return false
+	}
+}
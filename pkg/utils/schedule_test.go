@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const scheduleTestTimeout = 5 * time.Second
+
+// eventually wait for a condition, failing the test on timeout
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(scheduleTestTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runs record the starts of a job and its concurrent runs
+type runs struct {
+	mx      sync.Mutex
+	starts  []time.Time
+	running int32
+	overlap int32
+}
+
+func (r *runs) start() {
+	if atomic.AddInt32(&r.running, 1) > 1 {
+		atomic.StoreInt32(&r.overlap, 1)
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.starts = append(r.starts, time.Now())
+}
+
+func (r *runs) done() {
+	atomic.AddInt32(&r.running, -1)
+}
+
+func (r *runs) count() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return len(r.starts)
+}
+
+func (r *runs) overlapped() bool {
+	return atomic.LoadInt32(&r.overlap) == 1
+}
+
+func TestFixedRateSkipsOverlaps(t *testing.T) {
+	name := "test_fixed_rate"
+	r := &runs{}
+	release := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan error, 1)
+	go func() {
+		returned <- RunSchedule(ctx, Schedule{
+			ScheduleConfig: ScheduleConfig{Mode: ScheduleFixedRate},
+			Name:           name,
+			Interval:       10 * time.Millisecond,
+			Immediate:      true,
+		}, func(ctx context.Context) error {
+			r.start()
+			defer r.done()
+			<-release
+			return nil
+		})
+	}()
+
+	skipped := scheduleSkipped.WithLabelValues(name)
+	eventually(t, "skipped runs", func() bool {
+		return testutil.ToFloat64(skipped) >= 3
+	})
+	if r.count() != 1 {
+		t.Errorf("got %d runs while the first is running, want 1", r.count())
+	}
+
+	// runs start again once the running one returns
+	release <- struct{}{}
+	eventually(t, "a next run", func() bool {
+		return r.count() >= 2
+	})
+
+	// the schedule returns with its running job
+	cancel()
+	select {
+	case <-returned:
+		t.Fatal("got schedule returning before its running job")
+	case <-time.After(30 * time.Millisecond):
+	}
+	close(release)
+	if err := <-returned; err != nil {
+		t.Fatal(err)
+	}
+	if r.overlapped() {
+		t.Error("got overlapping runs")
+	}
+	if got := testutil.ToFloat64(scheduleRuns.WithLabelValues(name, RunOK)); got < 2 {
+		t.Errorf("got %v ok runs, want 2", got)
+	}
+}
+
+func TestFixedDelaySpacing(t *testing.T) {
+	interval := 30 * time.Millisecond
+	duration := 20 * time.Millisecond
+	r := &runs{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = RunSchedule(ctx, Schedule{
+			Interval:  interval,
+			Immediate: true,
+		}, func(ctx context.Context) error {
+			r.start()
+			defer r.done()
+			time.Sleep(duration)
+			return nil
+		})
+	}()
+
+	eventually(t, "4 runs", func() bool {
+		return r.count() >= 4
+	})
+	cancel()
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for i := 1; i < len(r.starts); i++ {
+		// the interval is waited after each run completes
+		if gap := r.starts[i].Sub(r.starts[i-1]); gap < interval+duration {
+			t.Errorf("got runs %d & %d started %v apart, want at least %v", i-1, i, gap, interval+duration)
+		}
+	}
+}
+
+func TestScheduleTimeout(t *testing.T) {
+	name := "test_timeout"
+	errs := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = RunSchedule(ctx, Schedule{
+			ScheduleConfig: ScheduleConfig{Timeout: 20 * time.Millisecond},
+			Name:           name,
+			Interval:       time.Hour,
+			Immediate:      true,
+		}, func(ctx context.Context) error {
+			<-ctx.Done()
+			errs <- ctx.Err()
+			return ctx.Err()
+		})
+	}()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got run context error %v, want deadline exceeded", err)
+		}
+	case <-time.After(scheduleTestTimeout):
+		t.Fatal("got run context not cancelled by the timeout")
+	}
+
+	timeouts := scheduleRuns.WithLabelValues(name, RunTimeout)
+	eventually(t, "the timeout status", func() bool {
+		return testutil.ToFloat64(timeouts) == 1
+	})
+	if got := testutil.ToFloat64(scheduleRuns.WithLabelValues(name, RunOK)); got != 0 {
+		t.Errorf("got %v ok runs, want 0", got)
+	}
+}
+
+func TestScheduleJitter(t *testing.T) {
+	s := Schedule{Interval: 100 * time.Millisecond}
+	if got := s.wait(); got != s.Interval {
+		t.Errorf("got wait %v without jitter, want %v", got, s.Interval)
+	}
+
+	s.Jitter = 0.5
+	max := s.Interval + s.Interval/2
+	waits := map[time.Duration]bool{}
+	for i := 0; i < 1000; i++ {
+		wait := s.wait()
+		if wait < s.Interval || wait > max {
+			t.Fatalf("got wait %v, want between %v and %v", wait, s.Interval, max)
+		}
+		waits[wait] = true
+	}
+	if len(waits) < 2 {
+		t.Errorf("got constant waits %v with jitter", waits)
+	}
+}
+
+func TestReloaderRestartsWithoutOverlap(t *testing.T) {
+	names := []string{"test_reload_before", "test_reload_after"}
+	mx := sync.Mutex{}
+	current := names[0]
+	load := func() (Schedule, bool) {
+		mx.Lock()
+		defer mx.Unlock()
+		return Schedule{
+			ScheduleConfig: ScheduleConfig{Mode: ScheduleFixedRate},
+			Name:           current,
+			Interval:       10 * time.Millisecond,
+			Immediate:      true,
+		}, true
+	}
+
+	r := &runs{}
+	release := make(chan struct{})
+	reloader := &Reloader{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan error, 1)
+	go func() {
+		returned <- reloader.RunSchedule(ctx, load, func(ctx context.Context) error {
+			r.start()
+			defer r.done()
+			<-release
+			return nil
+		})
+	}()
+	eventually(t, "the first run", func() bool {
+		return r.count() == 1
+	})
+
+	// the reload waits for the running job of the previous schedule
+	mx.Lock()
+	current = names[1]
+	mx.Unlock()
+	reloader.Reload()
+	time.Sleep(50 * time.Millisecond)
+	if r.count() != 1 {
+		t.Errorf("got %d runs before the running one returns, want 1", r.count())
+	}
+	close(release)
+
+	after := scheduleRuns.WithLabelValues(names[1], RunOK)
+	eventually(t, "runs of the reloaded schedule", func() bool {
+		return testutil.ToFloat64(after) >= 2
+	})
+	cancel()
+	if err := <-returned; err != nil {
+		t.Fatal(err)
+	}
+
+	if r.overlapped() {
+		t.Error("got overlapping runs across the reload")
+	}
+	if got := testutil.ToFloat64(scheduleRuns.WithLabelValues(names[0], RunOK)); got != 1 {
+		t.Errorf("got %v runs of the previous schedule, want 1", got)
+	}
+}